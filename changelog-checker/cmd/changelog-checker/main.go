@@ -0,0 +1,714 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+	"github.com/spf13/cobra"
+
+	"github.com/gjermundgaraba/changelog-checker/pkg/checker"
+	"github.com/gjermundgaraba/changelog-checker/pkg/db"
+	"github.com/gjermundgaraba/changelog-checker/pkg/github"
+	"github.com/gjermundgaraba/changelog-checker/pkg/types"
+)
+
+// secretKeys are the variables read from the --secrets-file
+var secretKeys = []string{"OPENAI_API_KEY", "GITHUB_TOKEN"}
+
+func main() {
+	// A missing .env file is fine, everything can also be set in the environment or with flags
+	_ = godotenv.Load()
+
+	// See checker.ExitCodeOK and friends for what the exit codes mean
+	exitCode := checker.ExitCodeOK
+	if err := newRootCmd(&exitCode).Execute(); err != nil {
+		os.Exit(checker.ExitCodeError)
+	}
+	os.Exit(exitCode)
+}
+
+// newRootCmd creates the root command, which sets exitCode from the check results
+func newRootCmd(exitCode *int) *cobra.Command {
+	var (
+		changelogFile string
+		versionTag    string
+		sinceVersion  string
+		limit         int
+		repoOwner     string
+		repoName      string
+		offline       bool
+
+		requireComponent bool
+		requireMerged    bool
+		knownComponents  []string
+		components       []string
+		verifyRef        string
+		commentOn        int
+		reviewFile       string
+
+		explainMismatches bool
+
+		failOn        []string
+		maxMismatches int
+
+		secretsFile string
+
+		flags checkerFlags
+	)
+
+	cmd := &cobra.Command{
+		Use:   "changelog-checker",
+		Short: "Check that changelog entries match the titles of the PRs they reference",
+		Long: `Check that changelog entries match the titles of the PRs they reference.
+
+Exit codes:
+  0  every entry matched its PR
+  1  potential mismatches or other problems with entries
+  2  PRs that couldn't be found
+  3  the check itself failed, including PRs that couldn't be looked up`,
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			if secretsFile == "" {
+				return nil
+			}
+
+			cmd.SilenceUsage = true
+			return loadSecretsFile(secretsFile)
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			failPolicy, err := parseFailPolicy(failOn, maxMismatches)
+			if err != nil {
+				return err
+			}
+
+			if sinceVersion != "" && versionTag != "" {
+				return fmt.Errorf("--since-version and --version-tag can't be used together")
+			}
+			if offline && commentOn > 0 {
+				return fmt.Errorf("--offline and --comment-on can't be used together")
+			}
+			if offline && flags.noCache {
+				return fmt.Errorf("--offline needs the cache database, it can't be used with --no-cache")
+			}
+
+			cmd.SilenceUsage = true
+
+			repoOwner, repoName = resolveRepo(repoOwner, repoName, changelogFile)
+
+			s, err := flags.setup(repoOwner, repoName)
+			if err != nil {
+				return err
+			}
+			defer s.Close()
+
+			// Nothing is sent to GitHub offline, so the token doesn't matter
+			if !offline {
+				if s.resolvedToken == "" {
+					log.Println("No GitHub token found, requests are unauthenticated and will be rate limited quickly")
+				} else if valid, err := s.githubClient.TestToken(); err != nil {
+					log.Printf("Failed to test GitHub token: %v", err)
+				} else if !valid {
+					return fmt.Errorf("GitHub token from %s is invalid or can't access %s/%s", s.tokenSource, repoOwner, repoName)
+				}
+			}
+
+			c := s.checker
+			c.SetRequireComponent(requireComponent)
+			c.SetRequireMerged(requireMerged)
+			c.SetKnownComponents(knownComponents)
+			c.SetComponentFilter(components)
+			c.SetVerifyRef(verifyRef)
+			c.SetExplainMismatches(explainMismatches)
+			c.OfflineOnly = offline
+
+			fmt.Println("Testing CHANGELOG entries")
+			var results []types.PRResult
+			if sinceVersion != "" {
+				results, err = checkSinceVersion(context.Background(), c, changelogFile, sinceVersion, limit)
+				if err != nil {
+					return err
+				}
+			} else {
+				results, err = c.CheckChangelog(context.Background(), changelogFile, versionTag, limit)
+				if err != nil {
+					return err
+				}
+
+				for _, result := range results {
+					if result.Status != types.StatusGoodMatch {
+						printResult(result)
+					}
+				}
+			}
+
+			counts := types.Summarize(results)
+			fmt.Printf("Processing %d PRs...\n", counts.PRs)
+			fmt.Println("✅ Good matches:", counts.Count(types.StatusGoodMatch))
+			fmt.Println("⚠️ Potential mismatches:", counts.Count(types.StatusPotentialMismatch))
+			fmt.Println("❌ Not found:", counts.Count(types.StatusNotFound))
+			if notMerged := counts.Count(types.StatusNotMerged); notMerged > 0 {
+				fmt.Println("🚫 Not merged:", notMerged)
+			}
+			if uncached := counts.Count(types.StatusUncached); uncached > 0 {
+				fmt.Println("💤 Not cached (run without --offline to fetch):", uncached)
+			}
+			if lookupErrors := counts.Count(types.StatusError); lookupErrors > 0 {
+				fmt.Println("💥 Lookup errors (worth retrying):", lookupErrors)
+			}
+
+			if s.database != nil {
+				stats := s.database.Stats()
+				fmt.Printf("%d PRs served from cache, %d fetched\n", stats.PRs.Hits, stats.PRs.Misses+stats.PRs.Expired)
+				if details := stats.Details; details.Hits+details.Misses+details.Expired > 0 {
+					fmt.Printf("%d PR details served from cache, %d fetched\n", details.Hits, details.Misses+details.Expired)
+				}
+			}
+
+			if s.openAIClient != nil {
+				usage := s.openAIClient.Usage()
+				fmt.Printf("OpenAI: %d tokens (~$%.3f)\n", usage.TotalTokens(), usage.EstimatedCost)
+			}
+
+			if s.database != nil {
+				summary := db.RunSummary{
+					RepoOwner:           repoOwner,
+					RepoName:            repoName,
+					VersionTag:          summaryVersion(versionTag, sinceVersion),
+					GoodMatches:         counts.Count(types.StatusGoodMatch),
+					PotentialMismatches: counts.Count(types.StatusPotentialMismatch),
+					NotFound:            counts.Count(types.StatusNotFound),
+				}
+				if err := s.database.StoreRunSummary(summary); err != nil {
+					log.Printf("Failed to record validation run: %v", err)
+				}
+			}
+
+			if reviewFile != "" {
+				if err := os.WriteFile(reviewFile, []byte(checker.FormatReviewList(results)), 0644); err != nil {
+					return fmt.Errorf("failed to write review list: %w", err)
+				}
+				fmt.Println("Wrote entries to review to", reviewFile)
+			}
+
+			if commentOn > 0 {
+				if err := s.githubClient.PostPRComment(repoOwner, repoName, commentOn, checker.FormatMarkdownReport(results)); err != nil {
+					return fmt.Errorf("failed to comment on PR #%d: %w", commentOn, err)
+				}
+				fmt.Printf("Posted the report on PR #%d\n", commentOn)
+			}
+
+			*exitCode = checker.ResultsToExitCodeWithPolicy(results, failPolicy)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&changelogFile, "changelog", "c", "CHANGELOG.md", "Path to the changelog file")
+	cmd.Flags().StringVar(&versionTag, "version-tag", "", "Version section to check (default Unreleased, or the latest version if there is none), latest-release skips Unreleased")
+	cmd.Flags().StringVar(&sinceVersion, "since-version", "", "Check every version section from the top of the changelog down to this version, inclusive")
+	cmd.Flags().IntVar(&limit, "limit", 0, "Only check this many PRs (0 checks all)")
+	cmd.Flags().StringVar(&repoOwner, "owner", os.Getenv("REPO_OWNER"), "GitHub repository owner (defaults to REPO_OWNER, then the git remote of the changelog's repository, then cosmos)")
+	cmd.Flags().StringVar(&repoName, "repo", os.Getenv("REPO_NAME"), "GitHub repository name (defaults to REPO_NAME, then the git remote of the changelog's repository, then ibc-go)")
+	cmd.Flags().BoolVar(&requireComponent, "require-component", false, "Flag entries without a component, e.g. the (api) in \"* (api) [\\#123](url) Description\"")
+	cmd.Flags().BoolVar(&requireMerged, "require-merged", false, "Flag entries whose PR was closed without being merged, leave it off for changelogs that reference issues")
+	cmd.Flags().StringSliceVar(&knownComponents, "known-components", nil, "Comma separated components entries may use, to catch typos")
+	cmd.Flags().StringSliceVar(&components, "components", nil, "Only check entries with one of these comma separated components, e.g. transfer")
+	cmd.Flags().IntVar(&commentOn, "comment-on", 0, "Post the report as a comment on this PR, updating the comment from earlier runs")
+	cmd.Flags().StringVar(&reviewFile, "review-file", "", "Write every entry that isn't a good match to this file, with the changelog description next to the PR title")
+	cmd.Flags().StringVar(&verifyRef, "verify-ref", "", "Flag PRs that weren't merged into or backported to this git ref, e.g. the v1.2.0 tag, to catch missing backports")
+	cmd.Flags().BoolVar(&explainMismatches, "explain-mismatches", false, "Ask OpenAI why each potential mismatch differs (one extra request per mismatch)")
+	cmd.Flags().StringSliceVar(&failOn, "fail-on", []string{"mismatch", "not-found"}, "Results that make the exit code non-zero: mismatch, not-found, or none")
+	cmd.Flags().IntVar(&maxMismatches, "max-mismatches", 0, "Number of potential mismatches tolerated before --fail-on=mismatch fails, other problems always fail")
+	cmd.Flags().BoolVar(&offline, "offline", false, "Only use cached PR info, without calling GitHub or the similarity backend, uncached PRs are reported as not cached")
+	flags.addFlags(cmd)
+	cmd.PersistentFlags().StringVar(&secretsFile, "secrets-file", "", "KEY=value or JSON file to read OPENAI_API_KEY and GITHUB_TOKEN from when they aren't set in the environment")
+
+	cmd.AddCommand(newPruneCmd())
+	cmd.AddCommand(newExportCacheCmd())
+	cmd.AddCommand(newImportCacheCmd())
+	cmd.AddCommand(newLintCmd(exitCode))
+	cmd.AddCommand(newPreflightCmd(exitCode))
+	cmd.AddCommand(newCheckPRCmd(exitCode))
+	cmd.AddCommand(newCrossVersionCmd())
+
+	return cmd
+}
+
+// parseFailPolicy builds the fail policy from the --fail-on and --max-mismatches flags
+func parseFailPolicy(failOn []string, maxMismatches int) (checker.FailPolicy, error) {
+	if maxMismatches < 0 {
+		return checker.FailPolicy{}, fmt.Errorf("--max-mismatches must not be negative, got %d", maxMismatches)
+	}
+
+	policy := checker.FailPolicy{MaxMismatches: maxMismatches}
+	for _, value := range failOn {
+		switch value {
+		case "mismatch":
+			policy.FailOnMismatch = true
+		case "not-found":
+			policy.FailOnNotFound = true
+		case "none":
+		default:
+			return checker.FailPolicy{}, fmt.Errorf("invalid --fail-on value %q, must be mismatch, not-found or none", value)
+		}
+	}
+
+	return policy, nil
+}
+
+// newLintCmd creates the lint command, which sets exitCode to checker.ExitCodeMismatch if there are issues
+func newLintCmd(exitCode *int) *cobra.Command {
+	var changelogFile string
+
+	cmd := &cobra.Command{
+		Use:   "lint",
+		Short: "Check the structure of the changelog: the [Unreleased] section, release dates and version order",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cmd.SilenceUsage = true
+
+			c := checker.NewChecker(nil, "", "", "", nil, false, nil)
+			issues, err := c.LintChangelogStructure(changelogFile)
+			if err != nil {
+				return err
+			}
+
+			for _, issue := range issues {
+				fmt.Printf("%s:%d: %s\n", changelogFile, issue.Line, issue.Message)
+			}
+			fmt.Printf("Found %d structural issues\n", len(issues))
+
+			if len(issues) > 0 {
+				*exitCode = checker.ExitCodeMismatch
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&changelogFile, "changelog", "c", "CHANGELOG.md", "Path to the changelog file")
+
+	return cmd
+}
+
+// newCrossVersionCmd creates the cross-version command, listing PRs referenced in more than one version section
+func newCrossVersionCmd() *cobra.Command {
+	var changelogFile string
+
+	cmd := &cobra.Command{
+		Use:   "cross-version",
+		Short: "List PRs referenced in more than one version section, to tell intentional backports from accidental duplicates",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cmd.SilenceUsage = true
+
+			c := checker.NewChecker(nil, "", "", "", nil, false, nil)
+			crossVersion, err := c.FindCrossVersionPRs(changelogFile)
+			if err != nil {
+				return err
+			}
+
+			prNumbers := make([]int, 0, len(crossVersion))
+			for prNumber := range crossVersion {
+				prNumbers = append(prNumbers, prNumber)
+			}
+			sort.Ints(prNumbers)
+
+			for _, prNumber := range prNumbers {
+				fmt.Printf("#%d: %s\n", prNumber, strings.Join(crossVersion[prNumber], ", "))
+			}
+			fmt.Printf("Found %d PRs in more than one version section\n", len(prNumbers))
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&changelogFile, "changelog", "c", "CHANGELOG.md", "Path to the changelog file")
+
+	return cmd
+}
+
+// newPreflightCmd creates the preflight command, which sets exitCode to checker.ExitCodeError if a check fails
+func newPreflightCmd(exitCode *int) *cobra.Command {
+	var (
+		changelogFile string
+		versionTag    string
+		repoOwner     string
+		repoName      string
+
+		flags checkerFlags
+	)
+
+	cmd := &cobra.Command{
+		Use:   "preflight",
+		Short: "Check the GitHub token, OpenAI key, cache database and changelog before a run",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cmd.SilenceUsage = true
+
+			repoOwner, repoName = resolveRepo(repoOwner, repoName, changelogFile)
+
+			// The same setup as a real run, so only the backends the run would use are checked
+			s, err := flags.setup(repoOwner, repoName)
+			if err != nil {
+				return err
+			}
+			defer s.Close()
+
+			report := s.checker.Preflight(changelogFile, versionTag)
+			fmt.Println(report)
+
+			if !report.OK() {
+				*exitCode = checker.ExitCodeError
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&changelogFile, "changelog", "c", "CHANGELOG.md", "Path to the changelog file")
+	cmd.Flags().StringVar(&versionTag, "version-tag", "", "Version section to check (default Unreleased, or the latest version if there is none)")
+	cmd.Flags().StringVar(&repoOwner, "owner", os.Getenv("REPO_OWNER"), "GitHub repository owner (defaults to REPO_OWNER, then the git remote of the changelog's repository, then cosmos)")
+	cmd.Flags().StringVar(&repoName, "repo", os.Getenv("REPO_NAME"), "GitHub repository name (defaults to REPO_NAME, then the git remote of the changelog's repository, then ibc-go)")
+	flags.addFlags(cmd)
+
+	return cmd
+}
+
+// newCheckPRCmd creates the check-pr command, which sets exitCode from the result like the root command
+func newCheckPRCmd(exitCode *int) *cobra.Command {
+	var (
+		repoOwner string
+		repoName  string
+
+		flags checkerFlags
+	)
+
+	cmd := &cobra.Command{
+		Use:     "check-pr <PR number> <description>",
+		Short:   "Check a changelog description against the title of a PR, without a changelog file",
+		Example: `  changelog-checker check-pr 123 "Add support for channel upgrades"`,
+		Args:    cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			prNumber, err := strconv.Atoi(strings.TrimPrefix(args[0], "#"))
+			if err != nil || prNumber <= 0 {
+				return fmt.Errorf("invalid PR number %q", args[0])
+			}
+
+			cmd.SilenceUsage = true
+
+			repoOwner, repoName = resolveRepo(repoOwner, repoName, ".")
+
+			s, err := flags.setup(repoOwner, repoName)
+			if err != nil {
+				return err
+			}
+			defer s.Close()
+
+			result, _ := s.checker.CheckPRAgainstDescription(context.Background(), prNumber, args[1])
+			printResult(result)
+
+			*exitCode = checker.ResultsToExitCode([]types.PRResult{result})
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&repoOwner, "owner", os.Getenv("REPO_OWNER"), "GitHub repository owner (defaults to REPO_OWNER, then the git remote of the current directory, then cosmos)")
+	cmd.Flags().StringVar(&repoName, "repo", os.Getenv("REPO_NAME"), "GitHub repository name (defaults to REPO_NAME, then the git remote of the current directory, then ibc-go)")
+	flags.addFlags(cmd)
+
+	return cmd
+}
+
+func newPruneCmd() *cobra.Command {
+	var olderThan time.Duration
+
+	cmd := &cobra.Command{
+		Use:   "prune",
+		Short: "Delete cached PR info, PR details and validation results older than --older-than",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cmd.SilenceUsage = true
+
+			database, err := db.NewDB(nil)
+			if err != nil {
+				return fmt.Errorf("failed to open cache database: %w", err)
+			}
+			defer database.Close()
+
+			removed, err := database.Prune(olderThan)
+			if err != nil {
+				return fmt.Errorf("failed to prune cache database: %w", err)
+			}
+
+			fmt.Printf("Removed %d cache entries older than %s\n", removed, olderThan)
+			return nil
+		},
+	}
+
+	cmd.Flags().DurationVar(&olderThan, "older-than", 30*24*time.Hour, "Remove entries fetched or validated longer ago than this")
+
+	return cmd
+}
+
+// newExportCacheCmd creates the export-cache command, writing the cache as JSON to a file or stdout
+func newExportCacheCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "export-cache [file]",
+		Short: "Export cached PR info, PR details and validation results as JSON, to stdout if no file is given",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cmd.SilenceUsage = true
+
+			database, err := db.NewDB(nil)
+			if err != nil {
+				return fmt.Errorf("failed to open cache database: %w", err)
+			}
+			defer database.Close()
+
+			if len(args) == 0 {
+				return database.Export(os.Stdout)
+			}
+
+			file, err := os.Create(args[0])
+			if err != nil {
+				return err
+			}
+			defer file.Close()
+
+			if err := database.Export(file); err != nil {
+				return fmt.Errorf("failed to export cache: %w", err)
+			}
+
+			fmt.Println("Exported the cache to", args[0])
+			return file.Close()
+		},
+	}
+}
+
+// newImportCacheCmd creates the import-cache command, loading a cache exported with export-cache
+func newImportCacheCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "import-cache [file]",
+		Short: "Import cached PR info, PR details and validation results written by export-cache, from stdin if no file is given",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cmd.SilenceUsage = true
+
+			database, err := db.NewDB(nil)
+			if err != nil {
+				return fmt.Errorf("failed to open cache database: %w", err)
+			}
+			defer database.Close()
+
+			input := os.Stdin
+			if len(args) == 1 {
+				input, err = os.Open(args[0])
+				if err != nil {
+					return err
+				}
+				defer input.Close()
+			}
+
+			if err := database.Import(input); err != nil {
+				return fmt.Errorf("failed to import cache: %w", err)
+			}
+
+			fmt.Println("Imported the cache")
+			return nil
+		},
+	}
+}
+
+// similarityBackends holds the configured clients and settings the similarity checkers are built from
+type similarityBackends struct {
+	// openAIClient is nil without an OpenAI key
+	openAIClient *checker.OpenAIClient
+	ollamaURL    string
+	ollamaModel  string
+
+	jaccardThreshold     float64
+	levenshteinThreshold float64
+}
+
+// newSimilarityChecker creates the similarity checker for the --similarity flag
+// openai uses the chat API if there is an OpenAI key and falls back to substring checks only without one,
+// ollama uses the --ollama-url server and --ollama-model, jaccard and levenshtein use their --*-threshold,
+// and every other name except substring and openai-embedding is looked up in the checker registry
+func newSimilarityChecker(name string, backends similarityBackends) (checker.SimilarityChecker, error) {
+	switch name {
+	case "openai":
+		if backends.openAIClient == nil {
+			return nil, nil
+		}
+		return backends.openAIClient, nil
+	case "openai-embedding":
+		if backends.openAIClient == nil {
+			return nil, fmt.Errorf("--similarity=openai-embedding needs OPENAI_API_KEY")
+		}
+		return checker.OpenAIEmbeddingChecker{OpenAIClient: backends.openAIClient}, nil
+	case "ollama":
+		return checker.NewOllamaClient(backends.ollamaURL, backends.ollamaModel), nil
+	case "jaccard":
+		if err := checkThreshold("--jaccard-threshold", backends.jaccardThreshold); err != nil {
+			return nil, err
+		}
+		return checker.NewJaccardChecker(backends.jaccardThreshold), nil
+	case "levenshtein":
+		if err := checkThreshold("--levenshtein-threshold", backends.levenshteinThreshold); err != nil {
+			return nil, err
+		}
+		return checker.NewLevenshteinChecker(backends.levenshteinThreshold), nil
+	case "substring":
+		return nil, nil
+	default:
+		return checker.NewSimilarityChecker(name)
+	}
+}
+
+// checkThreshold returns an error if a similarity threshold flag is outside 0 to 1
+func checkThreshold(flagName string, threshold float64) error {
+	if threshold < 0 || threshold > 1 {
+		return fmt.Errorf("%s must be between 0 and 1, got %g", flagName, threshold)
+	}
+	return nil
+}
+
+// envOr returns the environment variable, or fallback if it is empty
+func envOr(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
+
+// checkSinceVersion checks every version section from the top of the changelog down to sinceVersion,
+// printing the results that need attention under their version, and returns all results in changelog order
+func checkSinceVersion(ctx context.Context, c *checker.Checker, changelogFile, sinceVersion string, limit int) ([]types.PRResult, error) {
+	resultsByVersion, err := c.CheckChangelogRange(ctx, changelogFile, sinceVersion, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	tags, err := checker.VersionTags(changelogFile)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []types.PRResult
+	for _, tag := range tags {
+		versionResults, ok := resultsByVersion[tag]
+		if !ok {
+			continue
+		}
+		// Only keep the first section with the tag if the changelog repeats one
+		delete(resultsByVersion, tag)
+
+		fmt.Printf("== %s: %d PRs\n", tag, len(versionResults))
+		for _, result := range versionResults {
+			if result.Status != types.StatusGoodMatch {
+				printResult(result)
+			}
+		}
+		results = append(results, versionResults...)
+	}
+
+	return results, nil
+}
+
+// summaryVersion is the version recorded in the run summary, a range of versions is recorded as "since <version>"
+func summaryVersion(versionTag, sinceVersion string) string {
+	if sinceVersion != "" {
+		return "since " + sinceVersion
+	}
+	return versionTag
+}
+
+// printResult prints a single result that needs attention
+func printResult(result types.PRResult) {
+	fmt.Printf("%s: PR #%d\n", result.Status, result.Number)
+	if result.ChangelogDesc != "" {
+		fmt.Printf("  Changelog: %s\n", result.ChangelogDesc)
+	}
+	if result.PRTitle != "" {
+		fmt.Printf("  PR title:  %s\n", result.PRTitle)
+	}
+	if result.Explanation != "" {
+		fmt.Printf("  Why:       %s\n", result.Explanation)
+	}
+	if result.Error != nil {
+		fmt.Printf("  Error:     %v\n", result.Error)
+	}
+}
+
+// resolveRepo fills in the repository owner and name that weren't given from the origin remote of the git repository
+// the changelog is in, falling back to cosmos/ibc-go if that can't be read
+func resolveRepo(owner, name, changelogFile string) (string, string) {
+	if owner != "" && name != "" {
+		return owner, name
+	}
+
+	detectedOwner, detectedName, err := github.DetectRepo(filepath.Dir(changelogFile))
+	if err != nil {
+		log.Printf("Couldn't detect the repository from git, using the defaults: %v", err)
+		detectedOwner, detectedName = "cosmos", "ibc-go"
+	} else {
+		log.Printf("Detected repository %s/%s from the git remote", detectedOwner, detectedName)
+	}
+
+	if owner == "" {
+		owner = detectedOwner
+	}
+	if name == "" {
+		name = detectedName
+	}
+
+	return owner, name
+}
+
+// loadSecretsFile sets the secretKeys that aren't already in the environment from a KEY=value (.env style) or JSON
+// object file, so CI systems can mount secrets as a file instead of passing them on the command line or in the env
+// It warns if the file can be read by other users, and refuses anything that isn't a regular file
+func loadSecretsFile(fileName string) error {
+	info, err := os.Stat(fileName)
+	if err != nil {
+		return fmt.Errorf("failed to read secrets file: %w", err)
+	}
+	if !info.Mode().IsRegular() {
+		return fmt.Errorf("secrets file %s is not a regular file", fileName)
+	}
+	// Windows doesn't have Unix permission bits to check
+	if runtime.GOOS != "windows" && info.Mode().Perm()&0o004 != 0 {
+		log.Printf("WARNING: secrets file %s is readable by every user on this machine, restrict it with chmod 600", fileName)
+	}
+
+	bz, err := os.ReadFile(fileName)
+	if err != nil {
+		return fmt.Errorf("failed to read secrets file: %w", err)
+	}
+
+	var secrets map[string]string
+	if strings.HasPrefix(strings.TrimSpace(string(bz)), "{") {
+		if err := json.Unmarshal(bz, &secrets); err != nil {
+			return fmt.Errorf("failed to parse secrets file %s as JSON: %w", fileName, err)
+		}
+	} else if secrets, err = godotenv.Unmarshal(string(bz)); err != nil {
+		return fmt.Errorf("failed to parse secrets file %s: %w", fileName, err)
+	}
+
+	// Variables already in the environment take precedence
+	for _, key := range secretKeys {
+		if value := secrets[key]; value != "" && os.Getenv(key) == "" {
+			if err := os.Setenv(key, value); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
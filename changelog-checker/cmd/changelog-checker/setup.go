@@ -0,0 +1,149 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/gjermundgaraba/changelog-checker/pkg/checker"
+	"github.com/gjermundgaraba/changelog-checker/pkg/db"
+	"github.com/gjermundgaraba/changelog-checker/pkg/github"
+	"github.com/gjermundgaraba/changelog-checker/pkg/httpclient"
+)
+
+// checkerFlags are the flags shared by every command that checks entries against GitHub: the cache, the GitHub
+// client and the similarity backend. Each command registers them with addFlags and builds its checker with setup,
+// so the subcommands honor them just like the root command.
+type checkerFlags struct {
+	verbose  bool
+	noCache  bool
+	cacheTTL time.Duration
+
+	token           string
+	githubAPIURL    string
+	githubTimeout   time.Duration
+	waitOnRateLimit bool
+	openAITimeout   time.Duration
+	connectTimeout  time.Duration
+
+	similarity  string
+	normalize   []string
+	ollamaURL   string
+	ollamaModel string
+
+	jaccardThreshold     float64
+	levenshteinThreshold float64
+
+	openAIModel           string
+	openAISystemPrompt    string
+	openAIPromptPrice     float64
+	openAICompletionPrice float64
+}
+
+// addFlags registers the shared flags on the command
+func (f *checkerFlags) addFlags(cmd *cobra.Command) {
+	cmd.Flags().BoolVar(&f.noCache, "no-cache", false, "Keep the cache in memory for this run only instead of using the cache database")
+	cmd.Flags().DurationVar(&f.cacheTTL, "cache-ttl", db.DefaultTTL, "How long cached PR info and validation results are used, PR titles rarely change so a long TTL is safe")
+	cmd.Flags().StringVar(&f.token, "token", "", "GitHub token (defaults to GITHUB_TOKEN, then --secrets-file, then the gh CLI login for the --github-api-url host)")
+	cmd.Flags().StringVar(&f.githubAPIURL, "github-api-url", os.Getenv("GITHUB_API_URL"), "GitHub API base URL, e.g. https://github.example.com/api/v3 for GitHub Enterprise (defaults to GITHUB_API_URL, then "+github.DefaultBaseURL+")")
+	cmd.Flags().DurationVar(&f.githubTimeout, "github-timeout", httpclient.DefaultTimeout, "How long to wait for GitHub to start responding or send more data, raise it for large PR lists or a slow GitHub Enterprise")
+	cmd.Flags().BoolVar(&f.waitOnRateLimit, "wait-on-rate-limit", false, "Wait for the GitHub rate limit to reset and retry instead of failing, useful for long CI runs")
+	cmd.Flags().DurationVar(&f.openAITimeout, "openai-timeout", httpclient.DefaultTimeout, "How long to wait for OpenAI to start responding or send more data")
+	cmd.Flags().DurationVar(&f.connectTimeout, "connect-timeout", httpclient.DefaultConnectTimeout, "How long connecting to GitHub and OpenAI may take")
+	cmd.Flags().StringVar(&f.similarity, "similarity", "openai", fmt.Sprintf("Similarity check used when the changelog description doesn't contain the PR title: openai, openai-embedding, substring (none), or one of %s", strings.Join(checker.SimilarityCheckerNames(), ", ")))
+	cmd.Flags().StringSliceVar(&f.normalize, "normalize", checker.DefaultNormalizationNames, fmt.Sprintf("Comma separated steps applied in order to both texts before the substring check, from %s", strings.Join(checker.NormalizeStepNames(), ", ")))
+	cmd.Flags().Float64Var(&f.jaccardThreshold, "jaccard-threshold", checker.DefaultJaccardThreshold, "Share of shared words from 0 to 1 above which --similarity=jaccard considers texts similar")
+	cmd.Flags().Float64Var(&f.levenshteinThreshold, "levenshtein-threshold", checker.DefaultLevenshteinThreshold, "Edit distance similarity ratio from 0 to 1 above which --similarity=levenshtein considers texts similar")
+	cmd.Flags().StringVar(&f.ollamaURL, "ollama-url", envOr("OLLAMA_URL", checker.DefaultOllamaBaseURL), "Ollama server used by --similarity=ollama (defaults to OLLAMA_URL)")
+	cmd.Flags().StringVar(&f.ollamaModel, "ollama-model", envOr("OLLAMA_MODEL", checker.DefaultOllamaModel), "Ollama model used by --similarity=ollama (defaults to OLLAMA_MODEL)")
+	cmd.Flags().StringVar(&f.openAIModel, "openai-model", checker.DefaultOpenAIModel, "OpenAI chat model used for similarity checks")
+	cmd.Flags().StringVar(&f.openAISystemPrompt, "openai-system-prompt", "", "System prompt for OpenAI similarity checks (default is a generic similarity prompt)")
+	cmd.Flags().Float64Var(&f.openAIPromptPrice, "openai-prompt-price", checker.DefaultOpenAIPromptTokenPrice*1_000_000, "USD per million prompt tokens, used to estimate the OpenAI cost")
+	cmd.Flags().Float64Var(&f.openAICompletionPrice, "openai-completion-price", checker.DefaultOpenAICompletionTokenPrice*1_000_000, "USD per million completion tokens, used to estimate the OpenAI cost")
+	cmd.Flags().BoolVarP(&f.verbose, "verbose", "v", false, "Enable verbose logging")
+}
+
+// checkerSetup is the cache, GitHub client and checker built from checkerFlags
+type checkerSetup struct {
+	// database stays nil with --no-cache, which keeps everything in memory for this run only
+	database *db.DB
+	cache    db.Cache
+
+	githubClient  *github.Client
+	resolvedToken string
+	tokenSource   github.TokenSource
+
+	checker *checker.Checker
+	// openAIClient is only set when the similarity backend uses OpenAI, so its usage is only reported then
+	openAIClient *checker.OpenAIClient
+}
+
+// setup opens the cache and builds the GitHub client and checker for the repo, call Close when done
+func (f *checkerFlags) setup(repoOwner, repoName string) (*checkerSetup, error) {
+	normalization, err := checker.ParseNormalization(f.normalize)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &checkerSetup{}
+	if f.noCache {
+		s.cache = db.NewMemoryCache(f.cacheTTL)
+	} else {
+		s.database, err = db.NewDBWithTTL(nil, f.cacheTTL)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open cache database: %w", err)
+		}
+		s.cache = s.database
+	}
+
+	s.resolvedToken, s.tokenSource = github.ResolveToken(f.token, f.githubAPIURL)
+	s.githubClient = github.NewClient(s.resolvedToken, repoOwner, repoName, s.cache, nil,
+		github.WithBaseURL(f.githubAPIURL),
+		github.WithTimeout(f.githubTimeout),
+		github.WithConnectTimeout(f.connectTimeout),
+	)
+	s.githubClient.SetTokenSource(s.tokenSource)
+	s.githubClient.WaitOnRateLimit = f.waitOnRateLimit
+
+	openAIKey := os.Getenv("OPENAI_API_KEY")
+	s.checker = checker.NewChecker(s.githubClient, openAIKey, repoOwner, repoName, s.cache, f.verbose, nil)
+	var openAIClient *checker.OpenAIClient
+	if openAIKey != "" {
+		openAIClient = checker.NewOpenAIClient(openAIKey,
+			checker.WithModel(f.openAIModel),
+			checker.WithSystemPrompt(f.openAISystemPrompt),
+			checker.WithTokenPrices(f.openAIPromptPrice/1_000_000, f.openAICompletionPrice/1_000_000),
+			checker.WithTimeout(f.openAITimeout),
+			checker.WithConnectTimeout(f.connectTimeout),
+		)
+	}
+
+	similarityChecker, err := newSimilarityChecker(f.similarity, similarityBackends{
+		openAIClient:         openAIClient,
+		ollamaURL:            f.ollamaURL,
+		ollamaModel:          f.ollamaModel,
+		jaccardThreshold:     f.jaccardThreshold,
+		levenshteinThreshold: f.levenshteinThreshold,
+	})
+	if err != nil {
+		s.Close()
+		return nil, err
+	}
+	s.checker.SetSimilarityChecker(similarityChecker)
+	s.checker.SetNormalization(normalization)
+	if strings.HasPrefix(f.similarity, "openai") {
+		s.openAIClient = openAIClient
+	}
+
+	return s, nil
+}
+
+// Close closes the cache database, if any
+func (s *checkerSetup) Close() {
+	if s.database != nil {
+		s.database.Close()
+	}
+}
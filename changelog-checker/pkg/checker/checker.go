@@ -2,8 +2,10 @@ package checker
 
 import (
 	"bufio"
+	"context"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"os"
 	"regexp"
 	"strconv"
@@ -14,33 +16,154 @@ import (
 	"github.com/gjermundgaraba/changelog-checker/pkg/types"
 )
 
+// versionHeaderRegex matches a version section header with or without brackets, including semver pre-release and build metadata
+// e.g. ## [v1.2.0], ## [v1.2.0-rc1], ## [v1.2.0-beta.2] and ## [v1.2.0+ibc]
+var versionHeaderRegex = regexp.MustCompile(`^## \[?(v\d+\.\d+\.\d+(?:-[0-9A-Za-z-]+(?:\.[0-9A-Za-z-]+)*)?(?:\+[0-9A-Za-z-]+(?:\.[0-9A-Za-z-]+)*)?)\]?`)
+
 // Checker checks changelog entries against GitHub PR info
 type Checker struct {
-	githubClient *github.Client
-	openAIClient *OpenAIClient
-	db           *db.DB
-	repoOwner    string
-	repoName     string
-	verbose      bool
+	githubClient      *github.Client
+	similarityChecker SimilarityChecker
+	db                db.Cache
+	repoOwner         string
+	repoName          string
+	requireMerged     bool
+	requireComponent  bool
+	knownComponents   map[string]bool
+	componentFilter   map[string]bool
+	verifyRef         string
+	explainMismatches bool
+	normalization     []NormalizeStep
+	logger            *slog.Logger
+
+	// OfflineOnly makes the checker rely solely on cached PR info,
+	// without calling the GitHub API or the similarity backend
+	OfflineOnly bool
+
+	// OnProgress is called after each PR is checked, if set
+	OnProgress func(done, total int, current types.PRResult)
 }
 
 // NewChecker creates a new changelog checker
-func NewChecker(githubClient *github.Client, openAIKey, repoOwner, repoName string, database *db.DB, verbose bool) *Checker {
-	var openAIClient *OpenAIClient
+// A nil cache disables the validation cache
+// A nil logger defaults to a text handler on stderr, logging debug messages if verbose is set
+func NewChecker(githubClient *github.Client, openAIKey, repoOwner, repoName string, cache db.Cache, verbose bool, logger *slog.Logger) *Checker {
+	if logger == nil {
+		level := slog.LevelInfo
+		if verbose {
+			level = slog.LevelDebug
+		}
+		logger = slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: level}))
+	}
+
+	var similarityChecker SimilarityChecker
 	if openAIKey != "" {
-		openAIClient = NewOpenAIClient(openAIKey)
+		similarityChecker = NewOpenAIClient(openAIKey)
 	}
 
 	return &Checker{
-		githubClient: githubClient,
-		openAIClient: openAIClient,
-		db:           database,
-		repoOwner:    repoOwner,
-		repoName:     repoName,
-		verbose:      verbose,
+		githubClient:      githubClient,
+		similarityChecker: similarityChecker,
+		db:                cache,
+		repoOwner:         repoOwner,
+		repoName:          repoName,
+		logger:            logger,
 	}
 }
 
+// SetSimilarityChecker sets the LLM backend used when the substring check fails
+// e.g. OpenAIEmbeddingChecker{client} to use embeddings instead of the chat API
+// or a SimilarityCheckerFunc with scripted answers in tests
+// Passing nil disables LLM similarity checks
+func (c *Checker) SetSimilarityChecker(similarityChecker SimilarityChecker) {
+	c.similarityChecker = similarityChecker
+}
+
+// SetRequireMerged toggles flagging entries whose PR was never merged
+// Leave it off for repos whose changelogs reference issues rather than PRs
+func (c *Checker) SetRequireMerged(requireMerged bool) {
+	c.requireMerged = requireMerged
+}
+
+// SetRequireComponent toggles flagging entries without a component, e.g. the (api) in "* (api) [\#123](url) Description"
+func (c *Checker) SetRequireComponent(requireComponent bool) {
+	c.requireComponent = requireComponent
+}
+
+// SetKnownComponents sets the components entries may use, so typos like (ap) are flagged
+// Passing an empty list allows any component
+func (c *Checker) SetKnownComponents(components []string) {
+	c.knownComponents = nil
+	if len(components) == 0 {
+		return
+	}
+
+	c.knownComponents = make(map[string]bool, len(components))
+	for _, component := range components {
+		c.knownComponents[component] = true
+	}
+}
+
+// SetVerifyRef makes the checker flag entries whose PR isn't in the ref, e.g. the v1.2.0 tag when checking the v1.2.0 section
+// This catches PRs that were listed in a release but never cherry-picked or backported to it
+// An empty ref disables the check, which is also skipped when offline
+func (c *Checker) SetVerifyRef(ref string) {
+	c.verifyRef = ref
+}
+
+// SetNormalization sets the steps applied to both texts before the substring similarity check
+// Passing nil restores DefaultNormalization
+func (c *Checker) SetNormalization(steps []NormalizeStep) {
+	c.normalization = steps
+}
+
+// SetComponentFilter limits the check to entries with one of the components, e.g. "transfer" for "* (transfer) ..."
+// Passing an empty list checks every entry
+func (c *Checker) SetComponentFilter(components []string) {
+	c.componentFilter = nil
+	if len(components) == 0 {
+		return
+	}
+
+	c.componentFilter = make(map[string]bool, len(components))
+	for _, component := range components {
+		c.componentFilter[component] = true
+	}
+}
+
+// filterSectionByComponent blanks out the entries whose component isn't in the component filter
+// The lines are blanked rather than removed, so line numbers still point into the original section
+func (c *Checker) filterSectionByComponent(changelogSection string) string {
+	if c.componentFilter == nil {
+		return changelogSection
+	}
+
+	lines := strings.Split(changelogSection, "\n")
+	for i, line := range lines {
+		if isBulletLine(line) && !c.componentFilter[ExtractComponent(line)] {
+			lines[i] = ""
+		}
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// getPRTitle gets the PR title, only from the cache when offline
+func (c *Checker) getPRTitle(ctx context.Context, prNumber int) (string, error) {
+	if c.OfflineOnly {
+		return c.githubClient.GetCachedPRInfo(c.repoOwner, c.repoName, prNumber)
+	}
+	return c.githubClient.GetPRInfoCtx(ctx, c.repoOwner, c.repoName, prNumber)
+}
+
+// getPRMergeStatus gets the PR state and merge status, only from the cache when offline
+func (c *Checker) getPRMergeStatus(ctx context.Context, prNumber int) (string, bool, error) {
+	if c.OfflineOnly {
+		return c.githubClient.GetCachedPRMergeStatus(c.repoOwner, c.repoName, prNumber)
+	}
+	return c.githubClient.GetPRMergeStatusCtx(ctx, c.repoOwner, c.repoName, prNumber)
+}
+
 // ExtractPRNumbers extracts PR numbers from a changelog section
 func (c *Checker) ExtractPRNumbers(changelogSection string) []int {
 	var prNumbers []int
@@ -51,88 +174,291 @@ func (c *Checker) ExtractPRNumbers(changelogSection string) []int {
 	scanner := bufio.NewScanner(strings.NewReader(changelogSection))
 	for scanner.Scan() {
 		line := scanner.Text()
-		if strings.HasPrefix(line, "*") {
+		if isBulletLine(line) {
 			starLineCount++
-			if !strings.Contains(line, "[\\#") {
+			if len(lineReferences(line)) == 0 {
 				entryWithoutPR++
-				if c.verbose {
-					log.Printf("Entry without PR number: %s", line)
-				}
+				c.logger.Debug("Entry without PR number", "line", line)
 			}
 		}
 	}
-	log.Printf("Found %d changelog entries, entries without PR: %d", starLineCount, entryWithoutPR)
+	c.logger.Info("Found changelog entries", "entries", starLineCount, "without_pr", entryWithoutPR)
 
-	// Extract PR numbers using multiple patterns
+	// Extract PR numbers from both the bracketed [\#123] form and raw GitHub URLs
+	seen := make(map[int]bool)
+	scanner = bufio.NewScanner(strings.NewReader(changelogSection))
+	lineNum := 0
+	multiPRLine := 0
+	for scanner.Scan() {
+		lineNum++
+		line := scanner.Text()
+		references := lineReferences(line)
+		for _, number := range references {
+			if !seen[number] {
+				seen[number] = true
+				prNumbers = append(prNumbers, number)
+			}
+		}
 
-	// Pattern 1: Standard PR references: [\#123]
-	re := regexp.MustCompile(`\[\\#(\d+)\]`)
-	matches := re.FindAllStringSubmatch(changelogSection, -1)
+		// Debug output to see distribution of PR numbers
+		if isBulletLine(line) && len(references) > 1 {
+			multiPRLine++
+			c.logger.Debug("Line has multiple PR numbers", "line_number", lineNum, "line", line)
+		}
+	}
+	c.logger.Debug("Lines with multiple PR numbers", "count", multiPRLine)
 
-	for _, match := range matches {
-		if len(match) > 1 {
-			number, err := strconv.Atoi(match[1])
-			if err != nil {
-				continue
-			}
+	return prNumbers
+}
 
-			// Check if we've already seen this PR number
-			found := false
-			for _, pr := range prNumbers {
-				if pr == number {
-					found = true
-					break
-				}
-			}
+// entryBullets are the list markers changelog entries can start with, "* " is the most common but "- " and "+ " are
+// valid markdown bullets used by some repos
+var entryBullets = []string{"* ", "- ", "+ "}
 
-			if !found {
-				prNumbers = append(prNumbers, number)
-			}
+// isEntryStart reports whether the line starts a changelog entry with one of the entryBullets
+func isEntryStart(line string) bool {
+	for _, bullet := range entryBullets {
+		if strings.HasPrefix(line, bullet) {
+			return true
 		}
 	}
+	return false
+}
 
-	// Debug output to see distribution of PR numbers
-	scanner = bufio.NewScanner(strings.NewReader(changelogSection))
+// isBulletLine reports whether the line starts with a bullet, like isEntryStart but also accepting "*" without
+// a space. "-" and "+" need the space so horizontal rules like "---" aren't taken for entries.
+func isBulletLine(line string) bool {
+	return strings.HasPrefix(line, "*") || isEntryStart(line)
+}
+
+// FindEntriesWithoutPR returns the changelog entries that have no PR link
+// Only top-level "* ", "- " or "+ " bullets start an entry, indented continuation bullets are skipped
+func (c *Checker) FindEntriesWithoutPR(changelogSection string) []string {
+	var lines []string
+	for _, entry := range c.findEntriesWithoutPR(changelogSection) {
+		lines = append(lines, entry.line)
+	}
+
+	return lines
+}
+
+// entryLine is a changelog line along with its 1-based line number within the section
+type entryLine struct {
+	number int
+	line   string
+}
+
+func (c *Checker) findEntriesWithoutPR(changelogSection string) []entryLine {
+	var entries []entryLine
+	scanner := bufio.NewScanner(strings.NewReader(changelogSection))
 	lineNum := 0
-	multiPRLine := 0
 	for scanner.Scan() {
 		lineNum++
 		line := scanner.Text()
-		if strings.HasPrefix(line, "*") {
-			// Count PR references in this line
-			matches := re.FindAllStringSubmatch(line, -1)
-			if len(matches) > 1 {
-				multiPRLine++
-				if c.verbose {
-					log.Printf("Line %d has multiple PR numbers: %s", lineNum, line)
-				}
-			}
+		if isEntryStart(line) && len(lineReferences(line)) == 0 {
+			entries = append(entries, entryLine{number: lineNum, line: line})
 		}
 	}
-	if c.verbose {
-		log.Printf("Lines with multiple PR numbers: %d", multiPRLine)
+
+	return entries
+}
+
+// FindMalformedEntries returns the changelog entries with a PR reference that doesn't follow the
+// expected "[\#N](url) description" shape, e.g. a reference without a link or a missing description
+func (c *Checker) FindMalformedEntries(changelogSection string) []string {
+	var lines []string
+	for _, entry := range c.findMalformedEntries(changelogSection) {
+		lines = append(lines, entry.line)
 	}
 
-	return prNumbers
+	return lines
 }
 
-// findSection checks if a section with the given header exists
-func findSection(scanner *bufio.Scanner, header string) bool {
+func (c *Checker) findMalformedEntries(changelogSection string) []entryLine {
+	var entries []entryLine
+	scanner := bufio.NewScanner(strings.NewReader(changelogSection))
+	lineNum := 0
 	for scanner.Scan() {
-		if strings.HasPrefix(scanner.Text(), header) {
+		lineNum++
+		line := scanner.Text()
+		if isEntryStart(line) && c.isMalformedEntry(line) {
+			entries = append(entries, entryLine{number: lineNum, line: line})
+		}
+	}
+
+	return entries
+}
+
+// isMalformedEntry checks if an entry references PRs but is missing a link or a description for any of them
+func (c *Checker) isMalformedEntry(line string) bool {
+	references := lineReferences(line)
+	if len(references) == 0 {
+		return false
+	}
+
+	// Every bracketed reference needs a link
+	if len(prRefRegex.FindAllString(line, -1)) > len(prLinkRegex.FindAllString(line, -1)) {
+		return true
+	}
+
+	for _, prNumber := range references {
+		if c.GetPRDescriptionFromLine(line, prNumber) == "" {
 			return true
 		}
 	}
+
 	return false
 }
 
+// componentRegex matches the component at the start of an entry: * (component), or with a - or + bullet
+var componentRegex = regexp.MustCompile(`^[*+-] \(([^)]*)\)`)
+
+// ExtractComponent returns the component of a changelog entry, e.g. "api" for "* (api) [\#123](url) Description"
+// Returns an empty string if the entry has no component
+func ExtractComponent(line string) string {
+	if match := componentRegex.FindStringSubmatch(line); len(match) > 1 {
+		return strings.TrimSpace(match[1])
+	}
+
+	return ""
+}
+
+// findComponentIssues checks the component of every entry against the requirement and the known components
+// The section starts on line firstLine of the file, which the reported line numbers are relative to
+func (c *Checker) findComponentIssues(changelogSection string, firstLine int) []types.PRResult {
+	if !c.requireComponent && c.knownComponents == nil {
+		return nil
+	}
+
+	var results []types.PRResult
+	scanner := bufio.NewScanner(strings.NewReader(changelogSection))
+	lineNum := firstLine - 1
+	for scanner.Scan() {
+		lineNum++
+		line := scanner.Text()
+		if !isEntryStart(line) {
+			continue
+		}
+
+		var err error
+		component := ExtractComponent(line)
+		if component == "" && c.requireComponent {
+			err = fmt.Errorf("changelog entry on line %d has no component", lineNum)
+		} else if component != "" && c.knownComponents != nil && !c.knownComponents[component] {
+			err = fmt.Errorf("changelog entry on line %d has unknown component (%s)", lineNum, component)
+		}
+		if err == nil {
+			continue
+		}
+
+		result := types.PRResult{
+			ChangelogDesc: line,
+			Status:        types.StatusInvalidComponent,
+			Error:         err,
+			Lines:         []int{lineNum},
+		}
+		if references := lineReferences(line); len(references) > 0 {
+			result.Number = references[0]
+		}
+		results = append(results, result)
+	}
+
+	return results
+}
+
+// FindPRLines returns the line numbers of the changelog entries referencing each PR
+// Line numbers are 1-based and relative to the start of the section
+func (c *Checker) FindPRLines(changelogSection string) map[int][]int {
+	prLines := make(map[int][]int)
+
+	scanner := bufio.NewScanner(strings.NewReader(changelogSection))
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := scanner.Text()
+		if !isBulletLine(line) {
+			continue
+		}
+
+		for _, number := range lineReferences(line) {
+			prLines[number] = append(prLines[number], lineNum)
+		}
+	}
+
+	return prLines
+}
+
+// FindDuplicatePRs returns the PR numbers referenced on more than one changelog entry
+// mapped to the line numbers they appear on
+func (c *Checker) FindDuplicatePRs(changelogSection string) map[int][]int {
+	duplicates := make(map[int][]int)
+	for prNumber, lines := range c.FindPRLines(changelogSection) {
+		if len(lines) > 1 {
+			duplicates[prNumber] = lines
+		}
+	}
+
+	return duplicates
+}
+
+// findSection checks if a section for the given version tag exists
+func findSection(scanner *bufio.Scanner, versionTag string) bool {
+	for scanner.Scan() {
+		if isSectionHeader(scanner.Text(), versionTag) {
+			return true
+		}
+	}
+	return false
+}
+
+// sectionHeaderToken returns the version token of a "## " section header, e.g. "v1.0.0" for "## [v1.0.0](url) - 2024-01-31"
+// and "Unreleased" for "## Unreleased", "## Unreleased:", "## [Unreleased]" and "## [Unreleased] - 2024-01-01"
+func sectionHeaderToken(line string) (string, bool) {
+	if !strings.HasPrefix(line, "## ") {
+		return "", false
+	}
+
+	header := strings.TrimSpace(strings.TrimPrefix(line, "## "))
+	if strings.HasPrefix(header, "[") {
+		if idx := strings.Index(header, "]"); idx != -1 {
+			return strings.TrimSpace(header[1:idx]), true
+		}
+	}
+
+	// Without brackets the token ends at the first space, which drops suffixes like " - 2024-01-01"
+	token, _, _ := strings.Cut(header, " ")
+	return strings.TrimSuffix(token, ":"), true
+}
+
+// isSectionHeader reports whether the line is the section header for the version tag, ignoring case
+func isSectionHeader(line, versionTag string) bool {
+	token, ok := sectionHeaderToken(line)
+	return ok && strings.EqualFold(token, versionTag)
+}
+
+// LatestRelease is a version tag selecting the first released version section, skipping any Unreleased section
+const LatestRelease = "latest-release"
+
+// findLatestRelease returns the version of the first version section, which is the latest release
+// Returns an empty string if there are no version sections
+func findLatestRelease(scanner *bufio.Scanner) string {
+	for scanner.Scan() {
+		if match := versionHeaderRegex.FindStringSubmatch(scanner.Text()); len(match) > 1 {
+			return match[1]
+		}
+	}
+	return ""
+}
+
 // GetChangelogSection extracts the changelog section for a specific version
-func (c *Checker) GetChangelogSection(changelogFile, versionTag string) (string, error) {
-	file, err := os.Open(changelogFile)
+// An empty version tag selects Unreleased, or the latest release if there is no Unreleased section,
+// and LatestRelease always selects the latest release
+// It also returns the 1-based line number of the section header in the file, to report entries by their file line
+func (c *Checker) GetChangelogSection(changelogFile, versionTag string) (string, int, error) {
+	file, err := openChangelog(changelogFile)
 	if err != nil {
-		return "", err
+		return "", 0, err
 	}
-	defer file.Close()
 
 	scanner := bufio.NewScanner(file)
 	var sectionLines []string
@@ -144,48 +470,51 @@ func (c *Checker) GetChangelogSection(changelogFile, versionTag string) (string,
 		versionTag = "Unreleased"
 
 		// If we need to extract the latest version
-		if !findSection(scanner, fmt.Sprintf("## [%s]", versionTag)) {
+		if !findSection(scanner, versionTag) {
 			// Reset the scanner to start of file
 			file.Seek(0, 0)
 			scanner = bufio.NewScanner(file)
 
-			// Find the first version section (which is the latest version)
-			for scanner.Scan() {
-				line := scanner.Text()
-				if match := regexp.MustCompile(`^## \[(v\d+\.\d+\.\d+)\]`).FindStringSubmatch(line); len(match) > 1 {
-					versionTag = match[1]
-					break
-				}
+			if latest := findLatestRelease(scanner); latest != "" {
+				versionTag = latest
 			}
+		}
 
-			// Reset scanner again
-			file.Seek(0, 0)
-			scanner = bufio.NewScanner(file)
-		} else {
-			// Reset the scanner since we've consumed lines
-			file.Seek(0, 0)
-			scanner = bufio.NewScanner(file)
+		// Reset the scanner since we've consumed lines
+		file.Seek(0, 0)
+		scanner = bufio.NewScanner(file)
+	} else if versionTag == LatestRelease {
+		// Skip Unreleased even if it's there, the release itself is what's being checked
+		versionTag = findLatestRelease(scanner)
+		if versionTag == "" {
+			return "", 0, fmt.Errorf("no released version found in changelog file")
 		}
+
+		// Reset the scanner since we've consumed lines
+		file.Seek(0, 0)
+		scanner = bufio.NewScanner(file)
 	}
 
-	// Handle both formats: ## [v1.0.0] and ## [Unreleased]
-	versionHeader := fmt.Sprintf("## [%s]", versionTag)
-	if !strings.HasPrefix(versionTag, "v") && versionTag != "Unreleased" {
-		versionHeader = fmt.Sprintf("## [v%s]", versionTag)
+	// Handle both formats: ## [v1.0.0] and ## [Unreleased], with or without brackets
+	if !strings.HasPrefix(versionTag, "v") && !strings.EqualFold(versionTag, "Unreleased") {
+		versionTag = "v" + versionTag
 	}
 
+	lineNum, startLine := 0, 0
 	for scanner.Scan() {
+		lineNum++
 		line := scanner.Text()
 
 		// Start of our section
-		if strings.HasPrefix(line, versionHeader) {
+		if !inSection && isSectionHeader(line, versionTag) {
 			inSection = true
+			startLine = lineNum
 			sectionLines = append(sectionLines, line)
 			continue
 		}
 
 		// End of our section (new version section starts)
-		if inSection && strings.HasPrefix(line, "## [") {
+		if inSection && strings.HasPrefix(line, "## ") {
 			break
 		}
 
@@ -196,14 +525,54 @@ func (c *Checker) GetChangelogSection(changelogFile, versionTag string) (string,
 	}
 
 	if err := scanner.Err(); err != nil {
-		return "", err
+		return "", 0, err
 	}
 
 	if len(sectionLines) == 0 {
-		return "", fmt.Errorf("no section found for %s in changelog file", versionTag)
+		return "", 0, fmt.Errorf("no section found for %s in changelog file", versionTag)
+	}
+
+	return strings.Join(sectionLines, "\n"), startLine, nil
+}
+
+// GetChangelogSubsections splits the changelog section for a version by its ### headers
+// The map is keyed by header text (e.g. "Bug Fixes"). Any content before the first
+// ### header is keyed by "", which holds the whole body if there are no subsections.
+func (c *Checker) GetChangelogSubsections(changelogFile, versionTag string) (map[string]string, error) {
+	section, _, err := c.GetChangelogSection(changelogFile, versionTag)
+	if err != nil {
+		return nil, err
+	}
+
+	subsections := make(map[string]string)
+	current := ""
+	var currentLines []string
+	flush := func() {
+		body := strings.Join(currentLines, "\n")
+		if current != "" || strings.TrimSpace(body) != "" {
+			subsections[current] = body
+		}
+	}
+
+	// The first line is the version header itself
+	lines := strings.Split(section, "\n")[1:]
+	for _, line := range lines {
+		if strings.HasPrefix(line, "### ") {
+			flush()
+			current = strings.TrimSpace(strings.TrimPrefix(line, "### "))
+			currentLines = nil
+			continue
+		}
+		currentLines = append(currentLines, line)
 	}
+	flush()
 
-	return strings.Join(sectionLines, "\n"), nil
+	// No subsections at all, return the whole body
+	if len(subsections) == 0 {
+		subsections[""] = strings.Join(lines, "\n")
+	}
+
+	return subsections, nil
 }
 
 // GetPRDescriptionFromLine extracts the PR description from a changelog line
@@ -211,16 +580,16 @@ func (c *Checker) GetPRDescriptionFromLine(line string, prNumber int) string {
 	// Look for the PR number in the line
 	prRef := fmt.Sprintf("[\\#%d]", prNumber)
 	if !strings.Contains(line, prRef) {
-		return ""
+		return descriptionFromURLLine(line, prNumber)
 	}
 
-	// Format: * (component) [\#PR](url) Description
-	if match := regexp.MustCompile(`^\* \([^)]*\) \[\\#\d+\]\([^)]+\) (.+)$`).FindStringSubmatch(line); len(match) > 1 {
+	// Format: * (component) [\#PR](url) Description, or with a - or + bullet
+	if match := regexp.MustCompile(`^[*+-] \([^)]*\) \[\\#\d+\]\([^)]+\) (.+)$`).FindStringSubmatch(line); len(match) > 1 {
 		return match[1]
 	}
 
-	// Format: * [\#PR](url) Description
-	if match := regexp.MustCompile(`^\* \[\\#\d+\]\([^)]+\) (.+)$`).FindStringSubmatch(line); len(match) > 1 {
+	// Format: * [\#PR](url) Description, or with a - or + bullet
+	if match := regexp.MustCompile(`^[*+-] \[\\#\d+\]\([^)]+\) (.+)$`).FindStringSubmatch(line); len(match) > 1 {
 		return match[1]
 	}
 
@@ -232,28 +601,114 @@ func (c *Checker) GetPRDescriptionFromLine(line string, prNumber int) string {
 	return ""
 }
 
+var (
+	// prLinkRegex matches a PR reference with its link: [\#123](url)
+	prLinkRegex = regexp.MustCompile(`\[\\#(\d+)\]\(([^)]+)\)`)
+	// linkNumberRegex extracts the trailing pull/issue number from a GitHub URL
+	linkNumberRegex = regexp.MustCompile(`/(?:pull|pulls|issues)/(\d+)/?(?:[?#].*)?$`)
+	// prRefRegex matches a bracketed PR reference: [\#123]
+	prRefRegex = regexp.MustCompile(`\[\\#(\d+)\]`)
+	// prURLRegex matches a raw GitHub pull request or issue URL, optionally wrapped in parentheses
+	prURLRegex = regexp.MustCompile(`\(?https?://github\.com/[^/\s]+/[^/\s]+/(?:pull|issues)/(\d+)[^\s)]*\)?`)
+)
+
+// lineReferences returns the unique PR numbers referenced on a line in order of appearance
+// Bracketed references come first, followed by raw GitHub URLs that aren't the link of a bracketed reference
+func lineReferences(line string) []int {
+	var numbers []int
+	seen := make(map[int]bool)
+	add := func(match []string) {
+		number, err := strconv.Atoi(match[1])
+		if err != nil || seen[number] {
+			return
+		}
+		seen[number] = true
+		numbers = append(numbers, number)
+	}
+
+	for _, match := range prRefRegex.FindAllStringSubmatch(line, -1) {
+		add(match)
+	}
+
+	// Drop [\#123](url) links so their URL isn't counted as a separate reference
+	for _, match := range prURLRegex.FindAllStringSubmatch(prLinkRegex.ReplaceAllString(line, ""), -1) {
+		add(match)
+	}
+
+	return numbers
+}
+
+// descriptionFromURLLine extracts the description from an entry that references the PR with a raw URL
+// Format: * (component) Description https://github.com/org/repo/pull/123
+func descriptionFromURLLine(line string, prNumber int) string {
+	found := false
+	for _, match := range prURLRegex.FindAllStringSubmatch(line, -1) {
+		if number, err := strconv.Atoi(match[1]); err == nil && number == prNumber {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return ""
+	}
+
+	desc := line
+	if isEntryStart(line) {
+		desc = line[2:]
+	}
+	desc = regexp.MustCompile(`^\([^)]*\) `).ReplaceAllString(desc, "")
+	desc = prURLRegex.ReplaceAllString(desc, "")
+
+	return strings.Join(strings.Fields(desc), " ")
+}
+
+// FindLinkMismatch checks if the link for the PR reference in the line points at a different number
+// Returns the number the link points at and true if it disagrees with prNumber
+func (c *Checker) FindLinkMismatch(line string, prNumber int) (int, bool) {
+	for _, match := range prLinkRegex.FindAllStringSubmatch(line, -1) {
+		number, err := strconv.Atoi(match[1])
+		if err != nil || number != prNumber {
+			continue
+		}
+
+		linkMatch := linkNumberRegex.FindStringSubmatch(match[2])
+		if len(linkMatch) < 2 {
+			continue
+		}
+
+		linkNumber, err := strconv.Atoi(linkMatch[1])
+		if err != nil {
+			continue
+		}
+
+		if linkNumber != prNumber {
+			return linkNumber, true
+		}
+	}
+
+	return 0, false
+}
+
 // CheckSimilarity checks similarity between changelog description and PR title
 func (c *Checker) CheckSimilarity(changelogDesc, prTitle string) types.PRStatus {
-	// Simple similarity check
-	changelogLower := strings.ToLower(strings.ReplaceAll(changelogDesc, "`", ""))
-	prTitleLower := strings.ToLower(prTitle)
-
-	// Strip punctuation at the end
-	if idx := strings.Index(changelogLower, "."); idx != -1 {
-		changelogLower = changelogLower[:idx]
+	// Simple similarity check on the normalized texts, empty texts would be contained in anything
+	normalization := c.normalization
+	if normalization == nil {
+		normalization = DefaultNormalization
 	}
+	changelogNormalized := Normalize(changelogDesc, normalization)
+	prTitleNormalized := Normalize(prTitle, normalization)
 
-	if strings.Contains(prTitleLower, changelogLower) || strings.Contains(changelogLower, prTitleLower) {
+	if changelogNormalized != "" && prTitleNormalized != "" &&
+		(strings.Contains(prTitleNormalized, changelogNormalized) || strings.Contains(changelogNormalized, prTitleNormalized)) {
 		return types.StatusGoodMatch
 	}
 
-	// Try OpenAI similarity check if client is available
-	if c.openAIClient != nil {
-		similar, err := c.openAIClient.CheckSimilarity(prTitle, changelogDesc)
+	// Try LLM similarity check if a backend is available
+	if c.similarityChecker != nil && !c.OfflineOnly {
+		similar, err := c.similarityChecker.CheckSimilarity(prTitle, changelogDesc)
 		if err != nil {
-			if c.verbose {
-				log.Printf("OpenAI similarity check error: %v", err)
-			}
+			c.logger.Warn("Similarity check error", "error", err)
 		} else if similar {
 			return types.StatusGoodMatch
 		}
@@ -262,29 +717,75 @@ func (c *Checker) CheckSimilarity(changelogDesc, prTitle string) types.PRStatus
 	return types.StatusPotentialMismatch
 }
 
-// FindPRLineInSection finds the line containing a PR in the changelog section
-func (c *Checker) FindPRLineInSection(prNumber int, section string) string {
+// FindPRLineInSection finds the line containing a PR in the changelog section along with its line number
+// Line numbers are 1-based and relative to the start of the section, 0 if the PR isn't found
+func (c *Checker) FindPRLineInSection(prNumber int, section string) (string, int) {
 	scanner := bufio.NewScanner(strings.NewReader(section))
-	prRef := fmt.Sprintf("[\\#%d]", prNumber)
 
+	lineNum := 0
 	for scanner.Scan() {
+		lineNum++
 		line := scanner.Text()
-		if strings.Contains(line, prRef) {
-			return line
+		for _, number := range lineReferences(line) {
+			if number == prNumber {
+				return line, lineNum
+			}
 		}
 	}
 
-	return ""
+	return "", 0
+}
+
+// mismatchExplainer is implemented by similarity backends that can explain why two texts differ, like OpenAIClient
+type mismatchExplainer interface {
+	Explain(prTitle, changelogDesc string) (string, error)
+}
+
+// SetExplainMismatches toggles asking the similarity backend why potential mismatches differ
+// It costs an extra request per mismatch and only works with backends that can explain, like OpenAIClient
+func (c *Checker) SetExplainMismatches(explainMismatches bool) {
+	c.explainMismatches = explainMismatches
+}
+
+// explainMismatch sets the explanation of a potential mismatch, if enabled and the backend can explain
+// A failed explanation is only logged, since the mismatch itself is still valid
+func (c *Checker) explainMismatch(result *types.PRResult) {
+	if !c.explainMismatches || c.OfflineOnly || result.Status != types.StatusPotentialMismatch {
+		return
+	}
+
+	explainer, ok := c.similarityChecker.(mismatchExplainer)
+	if !ok {
+		return
+	}
+
+	explanation, err := explainer.Explain(result.PRTitle, result.ChangelogDesc)
+	if err != nil {
+		c.logger.Warn("Error explaining mismatch", "pr", result.Number, "error", err)
+		return
+	}
+	result.Explanation = explanation
+}
+
+// statusForError maps a failed PR lookup to StatusNotFound if GitHub has no such PR, and to StatusError otherwise,
+// so rate limits, network errors and 5xx responses can be retried instead of being reported as changelog problems
+func statusForError(err error) types.PRStatus {
+	if errors.Is(err, github.ErrNotFound) {
+		return types.StatusNotFound
+	}
+	return types.StatusError
 }
 
 // CheckPR checks a single PR
-func (c *Checker) CheckPR(prNumber int, changelogSection string) types.PRResult {
+func (c *Checker) CheckPR(ctx context.Context, prNumber int, changelogSection string) types.PRResult {
 	result := types.PRResult{
 		Number: prNumber,
 	}
 
 	// Find the PR line in the changelog
-	line := c.FindPRLineInSection(prNumber, changelogSection)
+	line, lineNum := c.FindPRLineInSection(prNumber, changelogSection)
+	result.LineNumber = lineNum
+	result.RawLine = line
 	if line == "" {
 		result.Status = types.StatusNotFound
 		result.Error = fmt.Errorf("PR #%d not found in changelog section", prNumber)
@@ -299,26 +800,64 @@ func (c *Checker) CheckPR(prNumber int, changelogSection string) types.PRResult
 		return result
 	}
 
+	// Make sure the link points at the same PR as the bracketed number
+	if linkNumber, mismatch := c.FindLinkMismatch(line, prNumber); mismatch {
+		result.Status = types.StatusLinkMismatch
+		result.Error = fmt.Errorf("PR #%d links to #%d", prNumber, linkNumber)
+		return result
+	}
+
+	// Flag entries whose PR was closed without merging or is still open
+	if c.requireMerged {
+		state, merged, err := c.getPRMergeStatus(ctx, prNumber)
+		if errors.Is(err, github.ErrNotCached) {
+			result.Status = types.StatusUncached
+			result.Error = err
+			return result
+		} else if err != nil {
+			result.Status = statusForError(err)
+			result.Error = err
+			return result
+		}
+
+		if !merged {
+			result.PRTitle, _ = c.getPRTitle(ctx, prNumber)
+			result.Status = types.StatusNotMerged
+			result.Error = fmt.Errorf("PR #%d is %s and was never merged", prNumber, state)
+			return result
+		}
+	}
+
+	// Flag entries whose PR didn't make it into the release
+	if c.verifyRef != "" && !c.OfflineOnly {
+		inRef, err := c.githubClient.VerifyPRsInRefCtx(ctx, c.repoOwner, c.repoName, []int{prNumber}, c.verifyRef)
+		if err != nil {
+			c.logger.Warn("Error checking if PR is in ref", "pr", prNumber, "ref", c.verifyRef, "error", err)
+		} else if !inRef[prNumber] {
+			result.PRTitle, _ = c.getPRTitle(ctx, prNumber)
+			result.Status = types.StatusNotInRef
+			result.Error = fmt.Errorf("PR #%d is referenced in the changelog but not present in %s", prNumber, c.verifyRef)
+			return result
+		}
+	}
+
 	// Check validation cache first
 	if c.db != nil {
-		status, found, err := c.db.GetValidationResult(c.repoOwner, c.repoName, prNumber, result.ChangelogDesc)
+		status, found, err := c.db.GetValidationResult(c.repoOwner, c.repoName, prNumber, result.ChangelogDesc, similarityMethod(c.similarityChecker))
 		if err != nil {
-			if c.verbose {
-				log.Printf("Error checking validation cache: %v", err)
-			}
+			c.logger.Warn("Error checking validation cache", "pr", prNumber, "error", err)
 		} else if found {
 			// Use cached validation result
-			if c.verbose {
-				log.Printf("Using cached validation result for PR #%d", prNumber)
-			}
+			c.logger.Debug("Using cached validation result", "pr", prNumber)
 			result.Status = types.PRStatus(status)
 
 			// Still need to get the PR title for display purposes
-			prTitle, err := c.githubClient.GetPRInfo(c.repoOwner, c.repoName, prNumber)
+			prTitle, err := c.getPRTitle(ctx, prNumber)
 			if err != nil {
 				result.Error = err
 			} else {
 				result.PRTitle = prTitle
+				c.explainMismatch(&result)
 			}
 
 			return result
@@ -326,9 +865,13 @@ func (c *Checker) CheckPR(prNumber int, changelogSection string) types.PRResult
 	}
 
 	// Cache miss or error - get PR title from GitHub API
-	prTitle, err := c.githubClient.GetPRInfo(c.repoOwner, c.repoName, prNumber)
-	if err != nil {
-		result.Status = types.StatusNotFound
+	prTitle, err := c.getPRTitle(ctx, prNumber)
+	if errors.Is(err, github.ErrNotCached) {
+		result.Status = types.StatusUncached
+		result.Error = err
+		return result
+	} else if err != nil {
+		result.Status = statusForError(err)
 		result.Error = err
 		return result
 	}
@@ -337,48 +880,131 @@ func (c *Checker) CheckPR(prNumber int, changelogSection string) types.PRResult
 
 	// Check similarity
 	result.Status = c.CheckSimilarity(result.ChangelogDesc, prTitle)
+	c.explainMismatch(&result)
 
-	// Store the validation result in cache
-	if c.db != nil {
-		if err := c.db.StoreValidationResult(c.repoOwner, c.repoName, prNumber, result.ChangelogDesc, int(result.Status)); err != nil {
-			if c.verbose {
-				log.Printf("Error caching validation result: %v", err)
-			}
+	// Store the validation result in cache, offline results skipped the similarity backend so don't keep them
+	if c.db != nil && !c.OfflineOnly {
+		if err := c.db.StoreValidationResult(c.repoOwner, c.repoName, prNumber, result.ChangelogDesc, similarityMethod(c.similarityChecker), int(result.Status)); err != nil {
+			c.logger.Warn("Error caching validation result", "pr", prNumber, "error", err)
 		}
 	}
 
 	return result
 }
 
-// CheckChangelog checks changelog entries against GitHub PR info
-// It returns the list of PRs found along with their validation status
-func (c *Checker) CheckChangelog(changelogFile, versionTag string, limit int) ([]types.PRResult, error) {
-	if c.verbose {
-		log.Printf("Checking Unreleased changelog entries...")
+// CheckPRAgainstDescription checks a description against the title of a PR without a changelog file,
+// e.g. to check an entry before writing it. The validation cache is not used, since the description is a draft.
+// The returned error is the PR lookup error, which is also set on the result.
+func (c *Checker) CheckPRAgainstDescription(ctx context.Context, prNumber int, description string) (types.PRResult, error) {
+	result := types.PRResult{
+		Number:        prNumber,
+		ChangelogDesc: description,
+	}
+
+	prTitle, err := c.getPRTitle(ctx, prNumber)
+	if errors.Is(err, github.ErrNotCached) {
+		result.Status = types.StatusUncached
+		result.Error = err
+		return result, err
+	} else if err != nil {
+		result.Status = statusForError(err)
+		result.Error = err
+		return result, err
 	}
+
+	result.PRTitle = prTitle
+	result.Status = c.CheckSimilarity(description, prTitle)
+
+	return result, nil
+}
+
+// CheckChangelog checks changelog entries against GitHub PR info
+// It returns the list of PRs found along with their validation status, in the order the PRs first appear in the section
+// If ctx is cancelled, it stops and returns the context error
+func (c *Checker) CheckChangelog(ctx context.Context, changelogFile, versionTag string, limit int) ([]types.PRResult, error) {
+	c.logger.Debug("Checking changelog entries", "file", changelogFile, "version", versionTag)
 	// Get the changelog section for the specified version
-	section, err := c.GetChangelogSection(changelogFile, versionTag)
+	section, startLine, err := c.GetChangelogSection(changelogFile, versionTag)
 	if err != nil {
 		return nil, err
 	}
 
+	// A section without PR references can still have entries without PR links or other issues worth reporting
+	results, err := c.checkSection(ctx, section, startLine, limit)
+	if errors.Is(err, errNoPRNumbers) {
+		c.logger.Warn("No PR numbers found in the changelog section", "file", changelogFile, "version", versionTag)
+	} else if err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+// errNoPRNumbers is returned by checkSection for sections without any PR references
+var errNoPRNumbers = errors.New("no PR numbers found in the changelog section")
+
+// checkSection checks the entries of a single changelog version section
+// Entries without a PR link, malformed entries and component issues come first, followed by the PRs in the order
+// they first appear in the section, so reports are stable no matter in which order PR info is fetched
+// If the section has no PR references, the entries without PR links are returned along with errNoPRNumbers
+// The section starts on line firstLine of the file, and all reported line numbers are relative to the file
+func (c *Checker) checkSection(ctx context.Context, section string, firstLine, limit int) ([]types.PRResult, error) {
+	section = c.filterSectionByComponent(section)
+	fileLine := func(sectionLine int) int {
+		return sectionLine + firstLine - 1
+	}
+
+	// Entries without a PR link can't be checked against GitHub, so report them as they are
+	var results []types.PRResult
+	for _, entry := range c.findEntriesWithoutPR(section) {
+		results = append(results, types.PRResult{
+			ChangelogDesc: entry.line,
+			Status:        types.StatusMissingPR,
+			Error:         fmt.Errorf("changelog entry on line %d has no PR link", fileLine(entry.number)),
+			Lines:         []int{fileLine(entry.number)},
+		})
+	}
+
+	// Malformed entries would otherwise show up as PRs that weren't found, so report the raw line instead
+	malformedLines := make(map[int]bool)
+	for _, entry := range c.findMalformedEntries(section) {
+		malformedLines[entry.number] = true
+		results = append(results, types.PRResult{
+			ChangelogDesc: entry.line,
+			Status:        types.StatusMalformed,
+			Error:         fmt.Errorf("changelog entry on line %d is malformed, expected \"[\\#N](url) description\"", fileLine(entry.number)),
+			Lines:         []int{fileLine(entry.number)},
+		})
+	}
+
+	results = append(results, c.findComponentIssues(section, firstLine)...)
+
 	// Extract PR numbers from the section
 	prNumbers := c.ExtractPRNumbers(section)
 	if len(prNumbers) == 0 {
-		return nil, fmt.Errorf("no PR numbers found in the changelog section")
+		return results, errNoPRNumbers
 	}
 
-	if c.verbose {
-		log.Printf("Found %d unique PR numbers in the changelog", len(prNumbers))
-		// Debug: Print all PR numbers
-		log.Printf("Found the following PR numbers in Unreleased section: %v", prNumbers)
+	// Only check PRs that are referenced by at least one well-formed entry
+	prLines := c.FindPRLines(section)
+	if len(malformedLines) > 0 {
+		var wellFormed []int
+		for _, prNumber := range prNumbers {
+			for _, line := range prLines[prNumber] {
+				if !malformedLines[line] {
+					wellFormed = append(wellFormed, prNumber)
+					break
+				}
+			}
+		}
+		prNumbers = wellFormed
 	}
 
+	c.logger.Debug("Found unique PR numbers in the changelog", "count", len(prNumbers), "prs", prNumbers)
+
 	// Apply limit if specified
 	if limit > 0 && limit < len(prNumbers) {
-		if c.verbose {
-			log.Printf("Limiting to %d PRs (test mode)", limit)
-		}
+		c.logger.Debug("Limiting PRs (test mode)", "limit", limit)
 		if limit == 3 {
 			// For testing, grab first, middle and last PR
 			middle := len(prNumbers) / 2
@@ -388,13 +1014,195 @@ func (c *Checker) CheckChangelog(changelogFile, versionTag string, limit int) ([
 		}
 	}
 
+	// Warm the PR cache with as few requests as possible, the per-PR checks below fall back to REST
+	// The returned map is deliberately ignored, results are built by walking prNumbers to keep the changelog order
+	if !c.OfflineOnly {
+		if _, err := c.githubClient.GetPRInfoBatchCtx(ctx, c.repoOwner, c.repoName, prNumbers); err != nil {
+			c.logger.Warn("Batch fetching PR info failed, falling back to fetching PRs one by one", "error", err)
+		}
+	}
+
 	// Check each PR
-	var results []types.PRResult
-	for _, prNumber := range prNumbers {
-		result := c.CheckPR(prNumber, section)
+	for i, prNumber := range prNumbers {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		var lines []int
+		for _, line := range prLines[prNumber] {
+			lines = append(lines, fileLine(line))
+		}
+
+		result := c.CheckPR(ctx, prNumber, section)
+		if result.LineNumber > 0 {
+			result.LineNumber = fileLine(result.LineNumber)
+		}
+		result.Lines = lines
 		results = append(results, result)
+
+		if c.OnProgress != nil {
+			c.OnProgress(i+1, len(prNumbers), result)
+		}
+
+		// Flag PRs that are referenced by more than one entry
+		if len(lines) > 1 {
+			results = append(results, types.PRResult{
+				Number:        prNumber,
+				ChangelogDesc: result.ChangelogDesc,
+				PRTitle:       result.PRTitle,
+				Status:        types.StatusDuplicate,
+				Error:         fmt.Errorf("PR #%d referenced on %d lines: %v", prNumber, len(lines), lines),
+				Lines:         lines,
+			})
+		}
 	}
 
 	return results, nil
 }
 
+// CheckChangelogs checks several changelog files against the same GitHub repo, e.g. one per module in a monorepo
+// The results are keyed by file path. PRs referenced in several files are only fetched once thanks to the db cache.
+func (c *Checker) CheckChangelogs(ctx context.Context, changelogFiles []string, versionTag string, limit int) (map[string][]types.PRResult, error) {
+	results := make(map[string][]types.PRResult)
+	for _, changelogFile := range changelogFiles {
+		fileResults, err := c.CheckChangelog(ctx, changelogFile, versionTag, limit)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", changelogFile, err)
+		}
+		results[changelogFile] = fileResults
+	}
+
+	return results, nil
+}
+
+// versionSection is a single "## [version]" section of a changelog
+type versionSection struct {
+	tag       string
+	content   string
+	startLine int // 1-based line number of the section header in the file
+}
+
+// splitVersionSections splits a changelog file into its "## [version]" sections, from the top of the file down
+func splitVersionSections(changelogFile string) ([]versionSection, error) {
+	file, err := openChangelog(changelogFile)
+	if err != nil {
+		return nil, err
+	}
+
+	var sections []versionSection
+	var current *versionSection
+	var lines []string
+	flush := func() {
+		if current != nil {
+			current.content = strings.Join(lines, "\n")
+			sections = append(sections, *current)
+		}
+	}
+
+	scanner := bufio.NewScanner(file)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := scanner.Text()
+		if tag, ok := sectionHeaderToken(line); ok {
+			flush()
+			current = &versionSection{tag: tag, startLine: lineNum}
+			lines = nil
+		}
+
+		if current != nil {
+			lines = append(lines, line)
+		}
+	}
+	flush()
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return sections, nil
+}
+
+// VersionTags returns the tags of the version sections in the changelog, from the top of the file down
+// e.g. to print the results of CheckChangelogRange in changelog order
+func VersionTags(changelogFile string) ([]string, error) {
+	sections, err := splitVersionSections(changelogFile)
+	if err != nil {
+		return nil, err
+	}
+
+	tags := make([]string, 0, len(sections))
+	for _, section := range sections {
+		tags = append(tags, section.tag)
+	}
+
+	return tags, nil
+}
+
+// FindCrossVersionPRs returns the PR numbers referenced in more than one version section of the changelog
+// mapped to the version tags they appear in, from the top of the file down. This is expected for backports to
+// maintenance branches, but can also be an entry that was accidentally left in or copied to the wrong section.
+func (c *Checker) FindCrossVersionPRs(changelogFile string) (map[int][]string, error) {
+	sections, err := splitVersionSections(changelogFile)
+	if err != nil {
+		return nil, err
+	}
+
+	versions := make(map[int][]string)
+	for _, section := range sections {
+		for prNumber := range c.FindPRLines(section.content) {
+			versions[prNumber] = append(versions[prNumber], section.tag)
+		}
+	}
+
+	crossVersion := make(map[int][]string)
+	for prNumber, tags := range versions {
+		if len(tags) > 1 {
+			crossVersion[prNumber] = tags
+		}
+	}
+
+	return crossVersion, nil
+}
+
+// CheckChangelogRange checks every version section from the top of the changelog down to fromVersion, inclusive
+// The results are keyed by version tag. PRs referenced in several versions are only fetched once thanks to the db cache.
+func (c *Checker) CheckChangelogRange(ctx context.Context, changelogFile, fromVersion string, limit int) (map[string][]types.PRResult, error) {
+	sections, err := splitVersionSections(changelogFile)
+	if err != nil {
+		return nil, err
+	}
+
+	// Accept the version with or without the "v" prefix
+	isFromVersion := func(tag string) bool {
+		return strings.TrimPrefix(tag, "v") == strings.TrimPrefix(fromVersion, "v")
+	}
+
+	found := false
+	for _, section := range sections {
+		if isFromVersion(section.tag) {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("no section found for %s in changelog file", fromVersion)
+	}
+
+	results := make(map[string][]types.PRResult)
+	for _, section := range sections {
+		c.logger.Debug("Checking changelog entries", "file", changelogFile, "version", section.tag)
+
+		sectionResults, err := c.checkSection(ctx, section.content, section.startLine, limit)
+		if err != nil && !errors.Is(err, errNoPRNumbers) {
+			return nil, fmt.Errorf("%s: %w", section.tag, err)
+		}
+		results[section.tag] = sectionResults
+
+		if isFromVersion(section.tag) {
+			break
+		}
+	}
+
+	return results, nil
+}
@@ -0,0 +1,434 @@
+package checker
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gjermundgaraba/changelog-checker/pkg/db"
+	"github.com/gjermundgaraba/changelog-checker/pkg/github"
+	"github.com/gjermundgaraba/changelog-checker/pkg/types"
+)
+
+// newTestChecker creates a checker without GitHub, cache or log output
+func newTestChecker() *Checker {
+	return NewChecker(nil, "", "", "", nil, false, slog.New(slog.NewTextHandler(io.Discard, nil)))
+}
+
+func TestCheckSimilarity(t *testing.T) {
+	tests := []struct {
+		name          string
+		changelogDesc string
+		prTitle       string
+		similar       bool
+		err           error
+		wantStatus    types.PRStatus
+		wantLLMCalled bool
+	}{
+		{
+			name:          "substring match skips the LLM",
+			changelogDesc: "Add wasm light client",
+			prTitle:       "feat: add wasm light client",
+			wantStatus:    types.StatusGoodMatch,
+			wantLLMCalled: false,
+		},
+		{
+			name:          "LLM says similar",
+			changelogDesc: "Support wasm clients",
+			prTitle:       "feat: add 08-wasm light client",
+			similar:       true,
+			wantStatus:    types.StatusGoodMatch,
+			wantLLMCalled: true,
+		},
+		{
+			name:          "LLM says not similar",
+			changelogDesc: "Remove deprecated params",
+			prTitle:       "feat: add 08-wasm light client",
+			similar:       false,
+			wantStatus:    types.StatusPotentialMismatch,
+			wantLLMCalled: true,
+		},
+		{
+			name:          "LLM error is a potential mismatch",
+			changelogDesc: "Support wasm clients",
+			prTitle:       "feat: add 08-wasm light client",
+			err:           errors.New("backend unavailable"),
+			wantStatus:    types.StatusPotentialMismatch,
+			wantLLMCalled: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			called := false
+			c := newTestChecker()
+			c.SetSimilarityChecker(SimilarityCheckerFunc(func(prTitle, changelogDesc string) (bool, error) {
+				called = true
+				if prTitle != tt.prTitle || changelogDesc != tt.changelogDesc {
+					t.Errorf("LLM called with (%q, %q), want (%q, %q)", prTitle, changelogDesc, tt.prTitle, tt.changelogDesc)
+				}
+				return tt.similar, tt.err
+			}))
+
+			if status := c.CheckSimilarity(tt.changelogDesc, tt.prTitle); status != tt.wantStatus {
+				t.Errorf("CheckSimilarity() = %s, want %s", status, tt.wantStatus)
+			}
+			if called != tt.wantLLMCalled {
+				t.Errorf("LLM called = %v, want %v", called, tt.wantLLMCalled)
+			}
+		})
+	}
+}
+
+// writeChangelog writes a changelog file to a temporary directory and returns its path
+func writeChangelog(t *testing.T, content string) string {
+	t.Helper()
+
+	changelogFile := filepath.Join(t.TempDir(), "CHANGELOG.md")
+	if err := os.WriteFile(changelogFile, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return changelogFile
+}
+
+// newFakeGitHub serves the PRs in titles over REST and GraphQL, sleeping a random time before every response
+// and returning the GraphQL nodes in a random order, so fetches complete in a shuffled order
+func newFakeGitHub(t *testing.T, titles map[int]string) *httptest.Server {
+	t.Helper()
+
+	aliasRegex := regexp.MustCompile(`pr(\d+): issueOrPullRequest`)
+	pullRegex := regexp.MustCompile(`/repos/[^/]+/[^/]+/(?:pulls|issues)/(\d+)$`)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(time.Duration(rand.Intn(5)) * time.Millisecond)
+
+		switch {
+		case r.URL.Path == "/graphql":
+			var request github.GraphQLRequest
+			if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+
+			matches := aliasRegex.FindAllStringSubmatch(request.Query, -1)
+			rand.Shuffle(len(matches), func(i, j int) { matches[i], matches[j] = matches[j], matches[i] })
+
+			nodes := make([]string, 0, len(matches))
+			for _, match := range matches {
+				number, _ := strconv.Atoi(match[1])
+				nodes = append(nodes, fmt.Sprintf(`"pr%d": {"__typename": "PullRequest", "title": %q, "state": "MERGED", "merged": true}`, number, titles[number]))
+			}
+			fmt.Fprintf(w, `{"data": {"repository": {%s}}}`, strings.Join(nodes, ", "))
+		case pullRegex.MatchString(r.URL.Path):
+			number, _ := strconv.Atoi(pullRegex.FindStringSubmatch(r.URL.Path)[1])
+			fmt.Fprintf(w, `{"title": %q, "state": "closed", "merged": true}`, titles[number])
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func TestCheckChangelogKeepsChangelogOrder(t *testing.T) {
+	// Deliberately not sorted, so sorting by number can't pass by accident
+	order := []int{42, 7, 100, 3, 58, 21, 9, 77}
+	titles := make(map[int]string)
+
+	var changelog strings.Builder
+	changelog.WriteString("# Changelog\n\n## [Unreleased]\n\n")
+	for _, number := range order {
+		titles[number] = fmt.Sprintf("Change number %d", number)
+		changelog.WriteString(fmt.Sprintf("* [\\#%d](https://github.com/o/r/pull/%d) Change number %d\n", number, number, number))
+	}
+	changelogFile := writeChangelog(t, changelog.String())
+
+	// Run it a few times, every run shuffles the fetches differently
+	for run := 0; run < 5; run++ {
+		server := newFakeGitHub(t, titles)
+		githubClient := github.NewClient("test-token", "o", "r", db.NewMemoryCache(0), slog.New(slog.NewTextHandler(io.Discard, nil)))
+		githubClient.SetBaseURL(server.URL)
+
+		c := NewChecker(githubClient, "", "o", "r", nil, false, slog.New(slog.NewTextHandler(io.Discard, nil)))
+		results, err := c.CheckChangelog(context.Background(), changelogFile, "", 0)
+		if err != nil {
+			t.Fatalf("CheckChangelog() error = %v", err)
+		}
+
+		if len(results) != len(order) {
+			t.Fatalf("got %d results, want %d", len(results), len(order))
+		}
+		for i, result := range results {
+			if result.Number != order[i] {
+				t.Fatalf("run %d: result %d is PR #%d, want #%d", run, i, result.Number, order[i])
+			}
+			if result.Status != types.StatusGoodMatch {
+				t.Errorf("run %d: PR #%d status = %s, want %s", run, result.Number, result.Status, types.StatusGoodMatch)
+			}
+		}
+	}
+}
+
+func TestCheckChangelogReportsFileLines(t *testing.T) {
+	changelogFile := writeChangelog(t, strings.Join([]string{
+		"---",
+		"title: Changelog",
+		"---",
+		"# Changelog",
+		"",
+		"## [Unreleased]",
+		"",
+		"* [\\#1](https://github.com/o/r/pull/1) First change",
+		"",
+		"## [v1.0.0] - 2024-01-31",
+		"",
+		"* [\\#2](https://github.com/o/r/pull/2) Second change",
+		"* An entry without a PR link",
+		"* [\\#2](https://github.com/o/r/pull/2) Second change again",
+	}, "\n"))
+
+	server := newFakeGitHub(t, map[int]string{1: "First change", 2: "Second change"})
+	githubClient := github.NewClient("test-token", "o", "r", db.NewMemoryCache(0), slog.New(slog.NewTextHandler(io.Discard, nil)))
+	githubClient.SetBaseURL(server.URL)
+
+	c := NewChecker(githubClient, "", "o", "r", nil, false, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	results, err := c.CheckChangelog(context.Background(), changelogFile, "v1.0.0", 0)
+	if err != nil {
+		t.Fatalf("CheckChangelog() error = %v", err)
+	}
+
+	got := make(map[types.PRStatus]types.PRResult)
+	for _, result := range results {
+		got[result.Status] = result
+	}
+
+	if missing := got[types.StatusMissingPR]; fmt.Sprint(missing.Lines) != "[13]" || !strings.Contains(missing.Error.Error(), "line 13") {
+		t.Errorf("missing PR link lines = %v, error = %v, want line 13", missing.Lines, missing.Error)
+	}
+	if match := got[types.StatusGoodMatch]; fmt.Sprint(match.Lines) != "[12 14]" || match.LineNumber != 12 {
+		t.Errorf("PR #2 lines = %v, line number = %d, want [12 14] and 12", match.Lines, match.LineNumber)
+	}
+	if duplicate := got[types.StatusDuplicate]; fmt.Sprint(duplicate.Lines) != "[12 14]" {
+		t.Errorf("duplicate lines = %v, want [12 14]", duplicate.Lines)
+	}
+}
+
+func TestCheckChangelogWithoutPRNumbers(t *testing.T) {
+	changelogFile := writeChangelog(t, "# Changelog\n\n## [Unreleased]\n\n* An entry without a PR link\n")
+	otherFile := writeChangelog(t, "# Changelog\n\n## [Unreleased]\n\n* Another entry without a PR link\n")
+
+	c := newTestChecker()
+	results, err := c.CheckChangelog(context.Background(), changelogFile, "", 0)
+	if err != nil {
+		t.Fatalf("CheckChangelog() error = %v", err)
+	}
+	if len(results) != 1 || results[0].Status != types.StatusMissingPR {
+		t.Errorf("CheckChangelog() = %v, want one %s result", results, types.StatusMissingPR)
+	}
+
+	resultsByFile, err := c.CheckChangelogs(context.Background(), []string{changelogFile, otherFile}, "", 0)
+	if err != nil {
+		t.Fatalf("CheckChangelogs() error = %v", err)
+	}
+	for _, file := range []string{changelogFile, otherFile} {
+		if got := resultsByFile[file]; len(got) != 1 || got[0].Status != types.StatusMissingPR {
+			t.Errorf("CheckChangelogs()[%s] = %v, want one %s result", file, got, types.StatusMissingPR)
+		}
+	}
+}
+
+func TestVersionHeaderRegex(t *testing.T) {
+	tests := []struct {
+		line        string
+		wantVersion string
+	}{
+		{line: "## [v1.2.3]", wantVersion: "v1.2.3"},
+		{line: "## [v1.2.3](https://github.com/o/r/releases/tag/v1.2.3) - 2024-01-31", wantVersion: "v1.2.3"},
+		{line: "## v1.2.3 - 2024-01-31", wantVersion: "v1.2.3"},
+		{line: "## v1.2.3", wantVersion: "v1.2.3"},
+		{line: "## [v1.2.0-rc1]", wantVersion: "v1.2.0-rc1"},
+		{line: "## [v1.2.0-beta.2]", wantVersion: "v1.2.0-beta.2"},
+		{line: "## [v1.2.0+ibc]", wantVersion: "v1.2.0+ibc"},
+		{line: "## v10.0.0-alpha.1+build.5 - 2024-01-31", wantVersion: "v10.0.0-alpha.1+build.5"},
+		{line: "## Unreleased"},
+		{line: "## [Unreleased]"},
+		{line: "## [1.2.3]"},
+		{line: "## [v1.2]"},
+		{line: "### [v1.2.3]"},
+		{line: "# [v1.2.3]"},
+		{line: "* [v1.2.3] bump dependency"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.line, func(t *testing.T) {
+			version := ""
+			if match := versionHeaderRegex.FindStringSubmatch(tt.line); len(match) > 1 {
+				version = match[1]
+			}
+			if version != tt.wantVersion {
+				t.Errorf("versionHeaderRegex matched %q, want %q", version, tt.wantVersion)
+			}
+		})
+	}
+}
+
+func TestFindLatestRelease(t *testing.T) {
+	tests := []struct {
+		name      string
+		changelog string
+		want      string
+	}{
+		{
+			name:      "first version from the top",
+			changelog: "# Changelog\n\n## [Unreleased]\n\n## [v1.2.0-rc1]\n\n## [v1.1.0]\n",
+			want:      "v1.2.0-rc1",
+		},
+		{
+			name:      "plain version",
+			changelog: "## v1.2.0 - 2024-01-31\n\n## v1.1.0 - 2023-12-01\n",
+			want:      "v1.2.0",
+		},
+		{
+			name:      "no versions",
+			changelog: "# Changelog\n\n## [Unreleased]\n",
+			want:      "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := findLatestRelease(bufio.NewScanner(strings.NewReader(tt.changelog))); got != tt.want {
+				t.Errorf("findLatestRelease() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsSectionHeader(t *testing.T) {
+	tests := []struct {
+		line       string
+		versionTag string
+		wantToken  string
+		want       bool
+	}{
+		{line: "## Unreleased", versionTag: "Unreleased", wantToken: "Unreleased", want: true},
+		{line: "## [Unreleased]", versionTag: "Unreleased", wantToken: "Unreleased", want: true},
+		{line: "## [Unreleased] - 2024-01-01", versionTag: "Unreleased", wantToken: "Unreleased", want: true},
+		{line: "## Unreleased:", versionTag: "Unreleased", wantToken: "Unreleased", want: true},
+		{line: "## unreleased", versionTag: "Unreleased", wantToken: "unreleased", want: true},
+		{line: "## [UNRELEASED]", versionTag: "unreleased", wantToken: "UNRELEASED", want: true},
+		{line: "## [v1.0.0](https://github.com/o/r/releases/tag/v1.0.0) - 2024-01-31", versionTag: "v1.0.0", wantToken: "v1.0.0", want: true},
+		{line: "## v1.0.0 - 2024-01-31", versionTag: "V1.0.0", wantToken: "v1.0.0", want: true},
+		{line: "## [v1.0.0]", versionTag: "v1.0.1", wantToken: "v1.0.0", want: false},
+		{line: "## [v1.0.0-rc1]", versionTag: "v1.0.0", wantToken: "v1.0.0-rc1", want: false},
+		{line: "### Unreleased", versionTag: "Unreleased", want: false},
+		{line: "### [v1.0.0]", versionTag: "v1.0.0", want: false},
+		{line: "# Unreleased", versionTag: "Unreleased", want: false},
+		{line: "##Unreleased", versionTag: "Unreleased", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.line, func(t *testing.T) {
+			token, _ := sectionHeaderToken(tt.line)
+			if token != tt.wantToken {
+				t.Errorf("sectionHeaderToken() = %q, want %q", token, tt.wantToken)
+			}
+			if got := isSectionHeader(tt.line, tt.versionTag); got != tt.want {
+				t.Errorf("isSectionHeader(%q) = %v, want %v", tt.versionTag, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsEntryStart(t *testing.T) {
+	tests := []struct {
+		line string
+		want bool
+	}{
+		{line: "* [\\#1](url) Star bullet", want: true},
+		{line: "- [\\#1](url) Dash bullet", want: true},
+		{line: "+ [\\#1](url) Plus bullet", want: true},
+		{line: "  - [\\#1](url) Nested bullet", want: false},
+		{line: "\t* [\\#1](url) Tab nested bullet", want: false},
+		{line: "  continuation of the entry above", want: false},
+		{line: "---", want: false},
+		{line: "-[\\#1](url) No space", want: false},
+		{line: "### Features", want: false},
+		{line: "", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.line, func(t *testing.T) {
+			if got := isEntryStart(tt.line); got != tt.want {
+				t.Errorf("isEntryStart() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFindEntriesWithoutPRBullets(t *testing.T) {
+	section := strings.Join([]string{
+		"## [Unreleased]",
+		"- [\\#1](https://github.com/o/r/pull/1) Dash entry",
+		"  which continues on the next line",
+		"  - nested bullet without a PR",
+		"+ Plus entry without a PR",
+		"    + nested plus bullet without a PR",
+		"* (api) Star entry without a PR",
+		"---",
+		"- Dash entry without a PR",
+	}, "\n")
+
+	want := []string{
+		"+ Plus entry without a PR",
+		"* (api) Star entry without a PR",
+		"- Dash entry without a PR",
+	}
+
+	got := newTestChecker().FindEntriesWithoutPR(section)
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("FindEntriesWithoutPR() = %q, want %q", got, want)
+	}
+}
+
+func TestBulletEntryParsing(t *testing.T) {
+	tests := []struct {
+		line          string
+		wantComponent string
+		wantDesc      string
+	}{
+		{line: "* (api) [\\#12](https://github.com/o/r/pull/12) Star entry", wantComponent: "api", wantDesc: "Star entry"},
+		{line: "- (api) [\\#12](https://github.com/o/r/pull/12) Dash entry", wantComponent: "api", wantDesc: "Dash entry"},
+		{line: "+ (core/04-channel) [\\#12](https://github.com/o/r/pull/12) Plus entry", wantComponent: "core/04-channel", wantDesc: "Plus entry"},
+		{line: "- [\\#12](https://github.com/o/r/pull/12) Dash entry without component", wantDesc: "Dash entry without component"},
+		{line: "+ [\\#12](https://github.com/o/r/pull/12) Plus entry without component", wantDesc: "Plus entry without component"},
+	}
+
+	c := newTestChecker()
+	for _, tt := range tests {
+		t.Run(tt.line, func(t *testing.T) {
+			if got := ExtractComponent(tt.line); got != tt.wantComponent {
+				t.Errorf("ExtractComponent() = %q, want %q", got, tt.wantComponent)
+			}
+			if got := c.GetPRDescriptionFromLine(tt.line, 12); got != tt.wantDesc {
+				t.Errorf("GetPRDescriptionFromLine() = %q, want %q", got, tt.wantDesc)
+			}
+		})
+	}
+}
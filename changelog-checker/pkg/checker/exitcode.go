@@ -0,0 +1,75 @@
+package checker
+
+import "github.com/gjermundgaraba/changelog-checker/pkg/types"
+
+// Exit codes of the changelog-checker CLI, so scripts can gate on the result, e.g. `changelog-checker || exit 1`
+// When results have several problems the most severe one wins, FailPolicy decides which problems count
+const (
+	// ExitCodeOK means every entry matched its PR
+	ExitCodeOK = 0
+	// ExitCodeMismatch means entries are potential mismatches or have other problems,
+	// e.g. a link mismatch, a missing PR link or a PR that was never merged
+	ExitCodeMismatch = 1
+	// ExitCodeNotFound means at least one PR couldn't be found
+	ExitCodeNotFound = 2
+	// ExitCodeError means the check itself failed, e.g. the changelog couldn't be read or GitHub couldn't be reached
+	// for some PRs, so it is worth retrying
+	ExitCodeError = 3
+)
+
+// FailPolicy decides which results fail the check
+type FailPolicy struct {
+	// FailOnMismatch fails the check when there are more than MaxMismatches potential mismatches
+	// or any other problem with an entry, like a duplicate or a PR that was never merged
+	FailOnMismatch bool
+	// FailOnNotFound fails the check when any PR couldn't be found
+	FailOnNotFound bool
+	// MaxMismatches is how many potential mismatches are tolerated, e.g. false positives from the heuristic
+	// Other problems are never tolerated, they don't depend on the heuristic
+	MaxMismatches int
+}
+
+// DefaultFailPolicy fails on any mismatch and any PR that couldn't be found
+func DefaultFailPolicy() FailPolicy {
+	return FailPolicy{
+		FailOnMismatch: true,
+		FailOnNotFound: true,
+	}
+}
+
+// ResultsToExitCode returns the exit code for the results using DefaultFailPolicy, the most severe status wins
+func ResultsToExitCode(results []types.PRResult) int {
+	return ResultsToExitCodeWithPolicy(results, DefaultFailPolicy())
+}
+
+// ResultsToExitCodeWithPolicy returns the exit code for the results under the policy, the most severe status wins
+// Lookup errors always fail with ExitCodeError, since the changelog couldn't be fully checked
+// Uncached results never fail, they weren't checked because the run was offline
+func ResultsToExitCodeWithPolicy(results []types.PRResult, policy FailPolicy) int {
+	notFound, mismatches, problems, lookupErrors := 0, 0, 0, 0
+	for _, result := range results {
+		switch result.Status {
+		case types.StatusGoodMatch, types.StatusUncached:
+		case types.StatusError:
+			lookupErrors++
+		case types.StatusNotFound:
+			notFound++
+		case types.StatusPotentialMismatch:
+			mismatches++
+		case types.StatusDuplicate, types.StatusNotMerged, types.StatusLinkMismatch, types.StatusMissingPR,
+			types.StatusMalformed, types.StatusInvalidComponent, types.StatusNotInRef:
+			problems++
+		}
+	}
+
+	switch {
+	case lookupErrors > 0:
+		return ExitCodeError
+	case policy.FailOnNotFound && notFound > 0:
+		return ExitCodeNotFound
+	case policy.FailOnMismatch && (problems > 0 || mismatches > policy.MaxMismatches):
+		return ExitCodeMismatch
+	default:
+		return ExitCodeOK
+	}
+}
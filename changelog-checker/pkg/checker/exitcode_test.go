@@ -0,0 +1,53 @@
+package checker
+
+import (
+	"testing"
+
+	"github.com/gjermundgaraba/changelog-checker/pkg/types"
+)
+
+func TestResultsToExitCodeWithPolicy(t *testing.T) {
+	results := func(statuses ...types.PRStatus) []types.PRResult {
+		var results []types.PRResult
+		for _, status := range statuses {
+			results = append(results, types.PRResult{Status: status})
+		}
+		return results
+	}
+
+	tolerant := FailPolicy{FailOnMismatch: true, FailOnNotFound: true, MaxMismatches: 2}
+
+	tests := []struct {
+		name    string
+		results []types.PRResult
+		policy  FailPolicy
+		want    int
+	}{
+		{"no results", nil, DefaultFailPolicy(), ExitCodeOK},
+		{"good matches", results(types.StatusGoodMatch, types.StatusGoodMatch), DefaultFailPolicy(), ExitCodeOK},
+		{"potential mismatch", results(types.StatusPotentialMismatch), DefaultFailPolicy(), ExitCodeMismatch},
+		{"potential mismatches within the limit", results(types.StatusPotentialMismatch, types.StatusPotentialMismatch), tolerant, ExitCodeOK},
+		{"potential mismatches over the limit", results(types.StatusPotentialMismatch, types.StatusPotentialMismatch, types.StatusPotentialMismatch), tolerant, ExitCodeMismatch},
+		{"duplicate isn't tolerated", results(types.StatusDuplicate), tolerant, ExitCodeMismatch},
+		{"not merged isn't tolerated", results(types.StatusNotMerged), tolerant, ExitCodeMismatch},
+		{"link mismatch isn't tolerated", results(types.StatusLinkMismatch), tolerant, ExitCodeMismatch},
+		{"missing PR link isn't tolerated", results(types.StatusMissingPR), tolerant, ExitCodeMismatch},
+		{"malformed entry isn't tolerated", results(types.StatusMalformed), tolerant, ExitCodeMismatch},
+		{"invalid component isn't tolerated", results(types.StatusInvalidComponent), tolerant, ExitCodeMismatch},
+		{"not in ref isn't tolerated", results(types.StatusNotInRef), tolerant, ExitCodeMismatch},
+		{"uncached doesn't fail", results(types.StatusUncached, types.StatusGoodMatch), DefaultFailPolicy(), ExitCodeOK},
+		{"not found", results(types.StatusNotFound, types.StatusPotentialMismatch), DefaultFailPolicy(), ExitCodeNotFound},
+		{"not found without fail on not found", results(types.StatusNotFound), FailPolicy{FailOnMismatch: true}, ExitCodeOK},
+		{"problems without fail on mismatch", results(types.StatusDuplicate, types.StatusPotentialMismatch), FailPolicy{FailOnNotFound: true}, ExitCodeOK},
+		{"lookup error wins", results(types.StatusNotFound, types.StatusError), DefaultFailPolicy(), ExitCodeError},
+		{"lookup error fails without any fail on", results(types.StatusError), FailPolicy{}, ExitCodeError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ResultsToExitCodeWithPolicy(tt.results, tt.policy); got != tt.want {
+				t.Errorf("ResultsToExitCodeWithPolicy() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
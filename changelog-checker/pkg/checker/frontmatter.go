@@ -0,0 +1,46 @@
+package checker
+
+import (
+	"os"
+	"strings"
+)
+
+// frontmatterDelimiters are the lines opening and closing a frontmatter block: "---" for YAML and "+++" for TOML
+var frontmatterDelimiters = []string{"---", "+++"}
+
+// openChangelog reads a changelog file with any frontmatter blanked out
+func openChangelog(changelogFile string) (*strings.Reader, error) {
+	content, err := os.ReadFile(changelogFile)
+	if err != nil {
+		return nil, err
+	}
+
+	return strings.NewReader(stripFrontmatter(string(content))), nil
+}
+
+// stripFrontmatter blanks out a metadata block at the very top of a changelog, opened and closed by a "---" (YAML)
+// or "+++" (TOML) line, so its lines aren't mistaken for entries. The lines are emptied rather than removed to keep
+// line numbers pointing at the file. Content without a closed frontmatter block is returned unchanged.
+func stripFrontmatter(content string) string {
+	lines := strings.Split(content, "\n")
+	opening := strings.TrimRight(lines[0], " \t\r")
+
+	for _, delimiter := range frontmatterDelimiters {
+		if opening != delimiter {
+			continue
+		}
+
+		for i := 1; i < len(lines); i++ {
+			if strings.TrimRight(lines[i], " \t\r") != delimiter {
+				continue
+			}
+
+			for j := 0; j <= i; j++ {
+				lines[j] = ""
+			}
+			return strings.Join(lines, "\n")
+		}
+	}
+
+	return content
+}
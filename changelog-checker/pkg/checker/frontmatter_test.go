@@ -0,0 +1,101 @@
+package checker
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestStripFrontmatter(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{
+			name:    "no frontmatter",
+			content: "# Changelog\n\n## [Unreleased]\n",
+			want:    "# Changelog\n\n## [Unreleased]\n",
+		},
+		{
+			name:    "yaml",
+			content: "---\ntitle: Changelog\n- not: an entry\n---\n# Changelog\n",
+			want:    "\n\n\n\n# Changelog\n",
+		},
+		{
+			name:    "toml",
+			content: "+++\ntitle = \"Changelog\"\n+++\n# Changelog\n",
+			want:    "\n\n\n# Changelog\n",
+		},
+		{
+			name:    "delimiters with trailing whitespace and CRLF",
+			content: "--- \r\ntitle: Changelog\r\n---\r\n# Changelog\r\n",
+			want:    "\n\n\n# Changelog\r\n",
+		},
+		{
+			name:    "unterminated block",
+			content: "---\ntitle: Changelog\n# Changelog\n* [\\#1](url) Entry\n",
+			want:    "---\ntitle: Changelog\n# Changelog\n* [\\#1](url) Entry\n",
+		},
+		{
+			name:    "mismatched delimiters",
+			content: "---\ntitle: Changelog\n+++\n# Changelog\n",
+			want:    "---\ntitle: Changelog\n+++\n# Changelog\n",
+		},
+		{
+			name:    "not at the top",
+			content: "# Changelog\n---\ntitle: Changelog\n---\n",
+			want:    "# Changelog\n---\ntitle: Changelog\n---\n",
+		},
+		{
+			name:    "empty",
+			content: "",
+			want:    "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := stripFrontmatter(tt.content)
+			if got != tt.want {
+				t.Errorf("stripFrontmatter() = %q, want %q", got, tt.want)
+			}
+			if strings.Count(got, "\n") != strings.Count(tt.content, "\n") {
+				t.Errorf("stripFrontmatter() has %d lines, want %d", strings.Count(got, "\n"), strings.Count(tt.content, "\n"))
+			}
+		})
+	}
+}
+
+func TestFrontmatterKeepsLineNumbers(t *testing.T) {
+	changelogFile := writeChangelog(t, strings.Join([]string{
+		"---",
+		"title: Changelog",
+		"* [\\#1](https://github.com/o/r/pull/1) Not an entry",
+		"---",
+		"# Changelog",
+		"## [Unreleased]",
+		"## [v1.0.0]",
+		"* [\\#2](https://github.com/o/r/pull/2) An entry",
+	}, "\n"))
+
+	c := newTestChecker()
+	section, startLine, err := c.GetChangelogSection(changelogFile, "v1.0.0")
+	if err != nil {
+		t.Fatalf("GetChangelogSection() error = %v", err)
+	}
+	if startLine != 7 {
+		t.Errorf("GetChangelogSection() start line = %d, want 7", startLine)
+	}
+	if got := c.ExtractPRNumbers(section); len(got) != 1 || got[0] != 2 {
+		t.Errorf("ExtractPRNumbers() = %v, want [2]", got)
+	}
+
+	// The version has no release date, which lint reports on its line in the file
+	issues, err := c.LintChangelogStructure(changelogFile)
+	if err != nil {
+		t.Fatalf("LintChangelogStructure() error = %v", err)
+	}
+	if len(issues) != 1 || issues[0].Line != 7 {
+		t.Errorf("LintChangelogStructure() = %v, want one issue on line 7", issues)
+	}
+}
@@ -0,0 +1,126 @@
+package checker
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	// DefaultJaccardThreshold is the share of shared words above which JaccardChecker considers texts similar
+	DefaultJaccardThreshold = 0.5
+	// DefaultLevenshteinThreshold is the similarity ratio above which LevenshteinChecker considers texts similar
+	DefaultLevenshteinThreshold = 0.6
+)
+
+// JaccardChecker is an offline SimilarityChecker comparing the sets of words in both texts
+// Texts are similar if the words they share make up at least Threshold of all their words
+type JaccardChecker struct {
+	Threshold float64
+}
+
+var _ SimilarityChecker = JaccardChecker{}
+
+// NewJaccardChecker creates a JaccardChecker with the given threshold between 0 and 1
+func NewJaccardChecker(threshold float64) JaccardChecker {
+	return JaccardChecker{Threshold: threshold}
+}
+
+// CheckSimilarity compares the words of the normalized texts
+func (c JaccardChecker) CheckSimilarity(prTitle, changelogDesc string) (bool, error) {
+	return JaccardSimilarity(prTitle, changelogDesc) >= c.Threshold, nil
+}
+
+// SimilarityMethod identifies the threshold, since it decides the verdict
+func (c JaccardChecker) SimilarityMethod() string {
+	return fmt.Sprintf("jaccard@%g", c.Threshold)
+}
+
+// JaccardSimilarity returns the number of words both normalized texts share divided by the number of distinct
+// words in either, from 0 for no shared words to 1 for the same words
+func JaccardSimilarity(a, b string) float64 {
+	wordsA := wordSet(Normalize(a, DefaultNormalization))
+	wordsB := wordSet(Normalize(b, DefaultNormalization))
+	if len(wordsA) == 0 || len(wordsB) == 0 {
+		return 0
+	}
+
+	shared := 0
+	for word := range wordsA {
+		if wordsB[word] {
+			shared++
+		}
+	}
+
+	return float64(shared) / float64(len(wordsA)+len(wordsB)-shared)
+}
+
+// wordSet returns the distinct words of a text
+func wordSet(text string) map[string]bool {
+	words := make(map[string]bool)
+	for _, word := range strings.Fields(text) {
+		words[word] = true
+	}
+
+	return words
+}
+
+// LevenshteinChecker is an offline SimilarityChecker comparing the edit distance between both texts
+// Texts are similar if their LevenshteinRatio is at least Threshold
+type LevenshteinChecker struct {
+	Threshold float64
+}
+
+var _ SimilarityChecker = LevenshteinChecker{}
+
+// NewLevenshteinChecker creates a LevenshteinChecker with the given threshold between 0 and 1
+func NewLevenshteinChecker(threshold float64) LevenshteinChecker {
+	return LevenshteinChecker{Threshold: threshold}
+}
+
+// CheckSimilarity compares the edit distance of the normalized texts
+func (c LevenshteinChecker) CheckSimilarity(prTitle, changelogDesc string) (bool, error) {
+	return LevenshteinRatio(prTitle, changelogDesc) >= c.Threshold, nil
+}
+
+// SimilarityMethod identifies the threshold, since it decides the verdict
+func (c LevenshteinChecker) SimilarityMethod() string {
+	return fmt.Sprintf("levenshtein@%g", c.Threshold)
+}
+
+// LevenshteinRatio returns 1 minus the edit distance between the normalized texts divided by the length of the
+// longer one, from 0 for completely different texts to 1 for the same text
+func LevenshteinRatio(a, b string) float64 {
+	runesA := []rune(Normalize(a, DefaultNormalization))
+	runesB := []rune(Normalize(b, DefaultNormalization))
+
+	longest := max(len(runesA), len(runesB))
+	if longest == 0 {
+		return 0
+	}
+
+	return 1 - float64(levenshteinDistance(runesA, runesB))/float64(longest)
+}
+
+// levenshteinDistance returns the number of single rune insertions, deletions and substitutions turning a into b
+func levenshteinDistance(a, b []rune) int {
+	// Only the previous row of the edit distance matrix is needed
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+
+	return previous[len(b)]
+}
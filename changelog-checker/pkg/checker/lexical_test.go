@@ -0,0 +1,111 @@
+package checker
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestJaccardChecker(t *testing.T) {
+	tests := []struct {
+		name      string
+		a         string
+		b         string
+		threshold float64
+		want      bool
+	}{
+		{name: "identical", a: "Add wasm light client", b: "add wasm light client.", threshold: DefaultJaccardThreshold, want: true},
+		{name: "disjoint", a: "Add wasm light client", b: "Remove deprecated params", threshold: DefaultJaccardThreshold, want: false},
+		// 2 shared words out of 4 distinct ones is exactly 0.5
+		{name: "at the threshold", a: "bump ibc-go", b: "bump ibc-go again later", threshold: 0.5, want: true},
+		{name: "just above the threshold", a: "bump ibc-go", b: "bump ibc-go again later", threshold: 0.51, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewJaccardChecker(tt.threshold).CheckSimilarity(tt.a, tt.b)
+			if err != nil {
+				t.Fatalf("CheckSimilarity() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("CheckSimilarity() = %v (similarity %g), want %v", got, JaccardSimilarity(tt.a, tt.b), tt.want)
+			}
+		})
+	}
+}
+
+func TestJaccardSimilarity(t *testing.T) {
+	if got := JaccardSimilarity("Add **wasm** client", "add wasm client"); got != 1 {
+		t.Errorf("identical after normalization = %g, want 1", got)
+	}
+	if got := JaccardSimilarity("add wasm client", "remove params"); got != 0 {
+		t.Errorf("disjoint = %g, want 0", got)
+	}
+	if got := JaccardSimilarity("", "add wasm client"); got != 0 {
+		t.Errorf("empty = %g, want 0", got)
+	}
+}
+
+func TestLevenshteinChecker(t *testing.T) {
+	tests := []struct {
+		name      string
+		a         string
+		b         string
+		threshold float64
+		want      bool
+	}{
+		{name: "identical", a: "Add wasm light client", b: "add `wasm` light client", threshold: DefaultLevenshteinThreshold, want: true},
+		{name: "disjoint", a: "abc", b: "xyz", threshold: DefaultLevenshteinThreshold, want: false},
+		// kitten to sitting is 3 edits over 7 runes, a ratio of 4/7 or about 0.571
+		{name: "just below the ratio", a: "kitten", b: "sitting", threshold: 0.57, want: true},
+		{name: "just above the ratio", a: "kitten", b: "sitting", threshold: 0.58, want: false},
+		{name: "typo", a: "Fix channel upgrade timeout", b: "Fix channel upgarde timeout", threshold: DefaultLevenshteinThreshold, want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewLevenshteinChecker(tt.threshold).CheckSimilarity(tt.a, tt.b)
+			if err != nil {
+				t.Fatalf("CheckSimilarity() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("CheckSimilarity() = %v (ratio %g), want %v", got, LevenshteinRatio(tt.a, tt.b), tt.want)
+			}
+		})
+	}
+}
+
+func TestLevenshteinDistance(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{a: "", b: "", want: 0},
+		{a: "", b: "abc", want: 3},
+		{a: "abc", b: "abc", want: 0},
+		{a: "kitten", b: "sitting", want: 3},
+		{a: "flaw", b: "lawn", want: 2},
+		{a: "héllo", b: "hello", want: 1},
+	}
+
+	for _, tt := range tests {
+		if got := levenshteinDistance([]rune(tt.a), []rune(tt.b)); got != tt.want {
+			t.Errorf("levenshteinDistance(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestSimilarityRegistry(t *testing.T) {
+	for _, name := range []string{"jaccard", "levenshtein", "ollama"} {
+		if _, err := NewSimilarityChecker(name); err != nil {
+			t.Errorf("NewSimilarityChecker(%q) error = %v", name, err)
+		}
+	}
+
+	_, err := NewSimilarityChecker("word2vec")
+	if err == nil {
+		t.Fatal("NewSimilarityChecker(word2vec) succeeded, want an unknown checker error")
+	}
+	if !strings.Contains(err.Error(), `"word2vec"`) || !strings.Contains(err.Error(), "jaccard, levenshtein") {
+		t.Errorf("NewSimilarityChecker(word2vec) error = %v, want the name and the registered checkers", err)
+	}
+}
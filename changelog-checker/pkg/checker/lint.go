@@ -0,0 +1,166 @@
+package checker
+
+import (
+	"bufio"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// LintIssue is a structural problem in the changelog file
+type LintIssue struct {
+	Line    int // 1-based line number in the changelog file
+	Message string
+}
+
+func (i LintIssue) String() string {
+	return fmt.Sprintf("line %d: %s", i.Line, i.Message)
+}
+
+// headerDateRegex matches the release date of a version header, e.g. ## [v1.2.0](url) - 2024-01-31
+var headerDateRegex = regexp.MustCompile(`\b\d{4}-\d{2}-\d{2}\b`)
+
+// LintChangelogStructure checks the version headers of the changelog file: there must be exactly one
+// [Unreleased] section and it must come first, every version needs a date and versions must be in descending order
+func (c *Checker) LintChangelogStructure(changelogFile string) ([]LintIssue, error) {
+	file, err := openChangelog(changelogFile)
+	if err != nil {
+		return nil, err
+	}
+
+	var issues []LintIssue
+	var unreleasedLines []int
+	headers := 0
+	previousVersion, previousLine := "", 0
+
+	scanner := bufio.NewScanner(file)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "## ") {
+			continue
+		}
+		headers++
+
+		if isSectionHeader(line, "Unreleased") {
+			unreleasedLines = append(unreleasedLines, lineNum)
+			if headers > 1 {
+				issues = append(issues, LintIssue{Line: lineNum, Message: "[Unreleased] must be the first section"})
+			}
+			continue
+		}
+
+		match := versionHeaderRegex.FindStringSubmatch(line)
+		if len(match) < 2 {
+			issues = append(issues, LintIssue{Line: lineNum, Message: fmt.Sprintf("unrecognized section header %q", line)})
+			continue
+		}
+		version := match[1]
+
+		if !headerDateRegex.MatchString(line[len(match[0]):]) {
+			issues = append(issues, LintIssue{Line: lineNum, Message: fmt.Sprintf("%s has no release date (YYYY-MM-DD)", version)})
+		}
+
+		if previousVersion != "" && compareVersions(version, previousVersion) >= 0 {
+			issues = append(issues, LintIssue{
+				Line:    lineNum,
+				Message: fmt.Sprintf("%s should come before %s on line %d, versions must be in descending order", version, previousVersion, previousLine),
+			})
+		}
+		previousVersion, previousLine = version, lineNum
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	switch {
+	case len(unreleasedLines) == 0:
+		issues = append([]LintIssue{{Line: 1, Message: "missing [Unreleased] section"}}, issues...)
+	case len(unreleasedLines) > 1:
+		for _, line := range unreleasedLines[1:] {
+			issues = append(issues, LintIssue{Line: line, Message: fmt.Sprintf("duplicate [Unreleased] section, the first one is on line %d", unreleasedLines[0])})
+		}
+	}
+
+	return issues, nil
+}
+
+// compareVersions compares two semver versions like v1.2.0 and v1.2.0-rc.1, ignoring build metadata
+// Returns -1, 0 or 1 if a is lower than, equal to or higher than b
+func compareVersions(a, b string) int {
+	aCore, aPre := splitVersion(a)
+	bCore, bPre := splitVersion(b)
+
+	for i := range 3 {
+		if aCore[i] != bCore[i] {
+			return compareInts(aCore[i], bCore[i])
+		}
+	}
+
+	// A pre-release is lower than the release itself
+	switch {
+	case aPre == "" && bPre == "":
+		return 0
+	case aPre == "":
+		return 1
+	case bPre == "":
+		return -1
+	}
+
+	aIdentifiers, bIdentifiers := strings.Split(aPre, "."), strings.Split(bPre, ".")
+	for i := 0; i < len(aIdentifiers) && i < len(bIdentifiers); i++ {
+		aNum, aErr := strconv.Atoi(aIdentifiers[i])
+		bNum, bErr := strconv.Atoi(bIdentifiers[i])
+		switch {
+		case aErr == nil && bErr == nil:
+			if aNum != bNum {
+				return compareInts(aNum, bNum)
+			}
+		case aErr == nil:
+			// Numeric identifiers are lower than alphanumeric ones
+			return -1
+		case bErr == nil:
+			return 1
+		default:
+			if cmp := strings.Compare(aIdentifiers[i], bIdentifiers[i]); cmp != 0 {
+				return cmp
+			}
+		}
+	}
+
+	return compareInts(len(aIdentifiers), len(bIdentifiers))
+}
+
+// splitVersion splits a version into its major, minor and patch numbers and its pre-release
+func splitVersion(version string) ([3]int, string) {
+	version = strings.TrimPrefix(version, "v")
+	if idx := strings.Index(version, "+"); idx != -1 {
+		version = version[:idx]
+	}
+
+	preRelease := ""
+	if idx := strings.Index(version, "-"); idx != -1 {
+		version, preRelease = version[:idx], version[idx+1:]
+	}
+
+	var core [3]int
+	for i, part := range strings.SplitN(version, ".", 3) {
+		core[i], _ = strconv.Atoi(part)
+	}
+
+	return core, preRelease
+}
+
+func compareInts(a, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
@@ -0,0 +1,123 @@
+package checker
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"v1.2.0", "v1.2.0", 0},
+		{"v1.2.0", "1.2.0", 0},
+		{"v1.2.1", "v1.2.0", 1},
+		{"v1.10.0", "v1.9.0", 1},
+		{"v2.0.0", "v1.99.99", 1},
+		{"v1.2.0-rc.1", "v1.2.0", -1},
+		{"v1.2.0", "v1.2.0-rc.1", 1},
+		{"v1.2.0-rc.1", "v1.2.0-rc.2", -1},
+		{"v1.2.0-rc.10", "v1.2.0-rc.9", 1},
+		{"v1.2.0-alpha", "v1.2.0-beta", -1},
+		{"v1.2.0-alpha", "v1.2.0-alpha.1", -1},
+		{"v1.2.0-1", "v1.2.0-alpha", -1},
+		{"v1.2.0-rc.1", "v1.1.9", 1},
+		{"v1.2.0+ibc", "v1.2.0", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.a+" vs "+tt.b, func(t *testing.T) {
+			if got := compareVersions(tt.a, tt.b); got != tt.want {
+				t.Errorf("compareVersions(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLintChangelogStructure(t *testing.T) {
+	tests := []struct {
+		name  string
+		lines []string
+		// want is the line of every issue, in order
+		want []int
+	}{
+		{
+			name:  "valid",
+			lines: []string{"# Changelog", "## [Unreleased]", "## [v1.2.0] - 2024-02-01", "## [v1.1.0] - 2024-01-01"},
+		},
+		{
+			name:  "bracketless and dated headers",
+			lines: []string{"# Changelog", "## Unreleased", "## v1.2.0 - 2024-02-01", "## [v1.1.0](https://github.com/o/r/releases/tag/v1.1.0) - 2024-01-01"},
+		},
+		{
+			name:  "pre-release below its release",
+			lines: []string{"## [Unreleased]", "## [v1.2.0] - 2024-02-01", "## [v1.2.0-rc.2] - 2024-01-20", "## [v1.2.0-rc.1] - 2024-01-10"},
+		},
+		{
+			name:  "pre-release above its release",
+			lines: []string{"## [Unreleased]", "## [v1.2.0-rc.1] - 2024-01-10", "## [v1.2.0] - 2024-02-01"},
+			want:  []int{3},
+		},
+		{
+			name:  "ascending versions",
+			lines: []string{"## [Unreleased]", "## [v1.1.0] - 2024-01-01", "## [v1.2.0] - 2024-02-01"},
+			want:  []int{3},
+		},
+		{
+			name:  "same version twice",
+			lines: []string{"## [Unreleased]", "## [v1.2.0] - 2024-02-01", "## [v1.2.0] - 2024-02-01"},
+			want:  []int{3},
+		},
+		{
+			name:  "missing date",
+			lines: []string{"## [Unreleased]", "## [v1.2.0]", "## v1.1.0"},
+			want:  []int{2, 3},
+		},
+		{
+			name:  "missing unreleased",
+			lines: []string{"# Changelog", "## [v1.2.0] - 2024-02-01"},
+			want:  []int{1},
+		},
+		{
+			name:  "unreleased not first",
+			lines: []string{"## [v1.2.0] - 2024-02-01", "## [Unreleased]"},
+			want:  []int{2},
+		},
+		{
+			name:  "duplicate unreleased",
+			lines: []string{"## [Unreleased]", "## Unreleased", "## [v1.2.0] - 2024-02-01"},
+			want:  []int{2, 2},
+		},
+		{
+			name:  "unrecognized header",
+			lines: []string{"## [Unreleased]", "## Notes"},
+			want:  []int{2},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			changelogFile := writeChangelog(t, strings.Join(tt.lines, "\n"))
+
+			issues, err := newTestChecker().LintChangelogStructure(changelogFile)
+			if err != nil {
+				t.Fatalf("LintChangelogStructure() error = %v", err)
+			}
+
+			var got []int
+			for _, issue := range issues {
+				got = append(got, issue.Line)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("LintChangelogStructure() = %v, want issues on lines %v", issues, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("LintChangelogStructure() = %v, want issues on lines %v", issues, tt.want)
+					break
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,107 @@
+package checker
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// NormalizeStep is a single step of the normalization applied to both texts before the substring similarity check
+type NormalizeStep func(string) string
+
+// DefaultNormalization is the ordered pipeline CheckSimilarity uses unless SetNormalization changes it:
+//  1. StripMarkdown removes links, emphasis and code spans, keeping their text
+//  2. strings.ToLower makes the comparison case insensitive
+//  3. CollapseWhitespace turns runs of whitespace into a single space, and trims the ends
+//  4. TrimTrailingPunctuation drops a trailing period or similar, without touching periods inside like "e.g."
+//
+// Markdown is stripped first since its syntax is punctuation, and whitespace is collapsed after stripping
+// since removed markup can leave double spaces behind.
+var DefaultNormalization = []NormalizeStep{
+	StripMarkdown,
+	strings.ToLower,
+	CollapseWhitespace,
+	TrimTrailingPunctuation,
+}
+
+// DefaultNormalizationNames names the DefaultNormalization steps, in order, for ParseNormalization
+var DefaultNormalizationNames = []string{"strip-markdown", "lowercase", "collapse-whitespace", "trim-punctuation"}
+
+// normalizeSteps are the steps ParseNormalization knows by name
+var normalizeSteps = map[string]NormalizeStep{
+	"strip-markdown":      StripMarkdown,
+	"lowercase":           strings.ToLower,
+	"collapse-whitespace": CollapseWhitespace,
+	"trim-punctuation":    TrimTrailingPunctuation,
+}
+
+// ParseNormalization returns the named steps in the given order, e.g. for the --normalize flag
+// An empty list returns an empty pipeline, which compares the texts as they are
+func ParseNormalization(names []string) ([]NormalizeStep, error) {
+	steps := make([]NormalizeStep, 0, len(names))
+	for _, name := range names {
+		step, ok := normalizeSteps[strings.TrimSpace(name)]
+		if !ok {
+			return nil, fmt.Errorf("unknown normalization step %q, known: %s", name, strings.Join(NormalizeStepNames(), ", "))
+		}
+		steps = append(steps, step)
+	}
+
+	return steps, nil
+}
+
+// NormalizeStepNames returns the names ParseNormalization accepts, sorted
+func NormalizeStepNames() []string {
+	names := make([]string, 0, len(normalizeSteps))
+	for name := range normalizeSteps {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// Normalize applies the steps to text in order
+func Normalize(text string, steps []NormalizeStep) string {
+	for _, step := range steps {
+		text = step(text)
+	}
+	return text
+}
+
+var (
+	// markdownImageRegex matches ![alt](url), keeping the alt text
+	markdownImageRegex = regexp.MustCompile(`!\[([^\]]*)\]\([^)]*\)`)
+	// markdownLinkRegex matches [text](url), keeping the text
+	markdownLinkRegex = regexp.MustCompile(`\[([^\]]*)\]\([^)]*\)`)
+	// markdownStrongRegex matches **text** and __text__
+	markdownStrongRegex = regexp.MustCompile(`\*\*([^*]+)\*\*|__([^_]+)__`)
+	// markdownStrikeRegex matches ~~text~~
+	markdownStrikeRegex = regexp.MustCompile(`~~([^~]+)~~`)
+	// markdownEmphasisRegex matches *text*, and _text_ only at word boundaries so snake_case identifiers are kept
+	markdownEmphasisRegex = regexp.MustCompile(`\*([^*\s][^*]*)\*|(^|[^\w])_([^_\s][^_]*)_([^\w]|$)`)
+)
+
+// StripMarkdown removes markdown links, images, emphasis and code spans from text, keeping the text they wrap
+func StripMarkdown(text string) string {
+	text = markdownImageRegex.ReplaceAllString(text, "$1")
+	text = markdownLinkRegex.ReplaceAllString(text, "$1")
+	text = markdownStrongRegex.ReplaceAllString(text, "$1$2")
+	text = markdownStrikeRegex.ReplaceAllString(text, "$1")
+	text = markdownEmphasisRegex.ReplaceAllString(text, "$1$2$3$4")
+	return strings.ReplaceAll(text, "`", "")
+}
+
+// CollapseWhitespace replaces every run of whitespace with a single space and trims the ends
+func CollapseWhitespace(text string) string {
+	return strings.Join(strings.Fields(text), " ")
+}
+
+// TrimTrailingPunctuation removes punctuation and whitespace from the end of text
+func TrimTrailingPunctuation(text string) string {
+	return strings.TrimRightFunc(text, func(r rune) bool {
+		return unicode.IsPunct(r) || unicode.IsSpace(r)
+	})
+}
@@ -0,0 +1,84 @@
+package checker
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestStripMarkdown(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{text: "plain text", want: "plain text"},
+		{text: "add [ICS20 v2](https://github.com/o/r/issues/1) support", want: "add ICS20 v2 support"},
+		{text: "see ![diagram](https://example.com/a.png)", want: "see diagram"},
+		{text: "make **transfer** faster", want: "make transfer faster"},
+		{text: "make __transfer__ faster", want: "make transfer faster"},
+		{text: "make *transfer* faster", want: "make transfer faster"},
+		{text: "make _transfer_ faster", want: "make transfer faster"},
+		{text: "remove ~~old~~ params", want: "remove old params"},
+		{text: "rename `MsgTransfer` field", want: "rename MsgTransfer field"},
+		{text: "keep snake_case_names intact", want: "keep snake_case_names intact"},
+		{text: "a * b * c", want: "a * b * c"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			if got := StripMarkdown(tt.text); got != tt.want {
+				t.Errorf("StripMarkdown() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDefaultNormalization(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{text: "Add Wasm light client.", want: "add wasm light client"},
+		{text: "Support e.g. foo and bar", want: "support e.g. foo and bar"},
+		{text: "  Trailing   whitespace  and punctuation!?  ", want: "trailing whitespace and punctuation"},
+		{text: "Bump **`ibc-go`** to [v8](https://example.com).", want: "bump ibc-go to v8"},
+		{text: "Remove `MsgFoo` from  the codec", want: "remove msgfoo from the codec"},
+		{text: "", want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			if got := Normalize(tt.text, DefaultNormalization); got != tt.want {
+				t.Errorf("Normalize() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseNormalization(t *testing.T) {
+	steps, err := ParseNormalization(DefaultNormalizationNames)
+	if err != nil {
+		t.Fatalf("ParseNormalization(DefaultNormalizationNames) error = %v", err)
+	}
+
+	text := "  Bump **`ibc-go`** to [v8](https://example.com).  "
+	if got, want := Normalize(text, steps), Normalize(text, DefaultNormalization); got != want {
+		t.Errorf("default names normalize to %q, DefaultNormalization to %q", got, want)
+	}
+
+	steps, err = ParseNormalization([]string{"lowercase"})
+	if err != nil {
+		t.Fatalf("ParseNormalization(lowercase) error = %v", err)
+	}
+	if got := Normalize("Keep **This**.", steps); got != "keep **this**." {
+		t.Errorf("lowercase only = %q, want %q", got, "keep **this**.")
+	}
+
+	steps, err = ParseNormalization(nil)
+	if err != nil || len(steps) != 0 {
+		t.Errorf("ParseNormalization(nil) = %d steps, %v, want none", len(steps), err)
+	}
+
+	if _, err := ParseNormalization([]string{"lowercase", "stem"}); err == nil || !strings.Contains(err.Error(), `"stem"`) {
+		t.Errorf("ParseNormalization(stem) error = %v, want an unknown step error", err)
+	}
+}
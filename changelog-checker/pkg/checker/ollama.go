@@ -0,0 +1,131 @@
+package checker
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultOllamaBaseURL is the default address of a local Ollama server
+	DefaultOllamaBaseURL = "http://localhost:11434"
+	// DefaultOllamaModel is the model used when none is specified
+	DefaultOllamaModel = "llama3"
+)
+
+// OllamaClient is a simple client for a local Ollama server
+type OllamaClient struct {
+	baseURL    string
+	model      string
+	httpClient *http.Client
+}
+
+var _ SimilarityChecker = (*OllamaClient)(nil)
+
+// NewOllamaClient creates a new Ollama client
+// Empty baseURL or model falls back to the defaults
+func NewOllamaClient(baseURL, model string) *OllamaClient {
+	if baseURL == "" {
+		baseURL = DefaultOllamaBaseURL
+	}
+	if model == "" {
+		model = DefaultOllamaModel
+	}
+
+	return &OllamaClient{
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		model:   model,
+		httpClient: &http.Client{
+			// Local models can be slow, especially on the first request
+			Timeout: 60 * time.Second,
+		},
+	}
+}
+
+// SimilarityMethod identifies the Ollama model used by CheckSimilarity
+func (c *OllamaClient) SimilarityMethod() string {
+	return "ollama:" + c.model
+}
+
+// OllamaChatRequest represents a request to the Ollama Chat API
+type OllamaChatRequest struct {
+	Model    string    `json:"model"`
+	Messages []Message `json:"messages"`
+	Stream   bool      `json:"stream"`
+}
+
+// OllamaChatResponse represents a response from the Ollama Chat API
+type OllamaChatResponse struct {
+	Message struct {
+		Content string `json:"content"`
+	} `json:"message"`
+	Error string `json:"error"`
+}
+
+// CheckSimilarity checks if two texts are similar in meaning using the Ollama API
+func (c *OllamaClient) CheckSimilarity(prTitle, changelogDesc string) (bool, error) {
+	// Create request
+	chatRequest := OllamaChatRequest{
+		Model: c.model,
+		Messages: []Message{
+			{
+				Role:    "system",
+				Content: "You are a helpful assistant that determines if two texts are similar in meaning.",
+			},
+			{
+				Role:    "user",
+				Content: fmt.Sprintf("PR Title: %s\nChangelog Description: %s\n\nAre these two texts describing the same change? Answer only YES or NO.", prTitle, changelogDesc),
+			},
+		},
+		Stream: false,
+	}
+
+	// Convert to JSON
+	jsonData, err := json.Marshal(chatRequest)
+	if err != nil {
+		return false, err
+	}
+
+	// Create HTTP request
+	req, err := http.NewRequest("POST", c.baseURL+"/api/chat", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return false, err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	// Send request
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	// Read response
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return false, err
+	}
+
+	// Parse response
+	var chatResponse OllamaChatResponse
+	if err := json.Unmarshal(body, &chatResponse); err != nil {
+		return false, err
+	}
+
+	// Check for error
+	if chatResponse.Error != "" {
+		return false, fmt.Errorf("Ollama API error: %s", chatResponse.Error)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("Ollama API returned status %d", resp.StatusCode)
+	}
+
+	// Check if answer contains YES
+	return strings.Contains(strings.ToUpper(chatResponse.Message.Content), "YES"), nil
+}
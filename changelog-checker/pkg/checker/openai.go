@@ -2,30 +2,150 @@ package checker
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
+	"hash/fnv"
 	"io"
+	"math"
+	"math/rand"
 	"net/http"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
+
+	"github.com/gjermundgaraba/changelog-checker/pkg/httpclient"
+)
+
+const (
+	// openAIMaxAttempts is how many times a request is tried before giving up
+	openAIMaxAttempts = 4
+	// openAIBaseBackoff is the wait before the first retry, doubled for each following retry
+	openAIBaseBackoff = 500 * time.Millisecond
+	// openAIMaxElapsed is the overall deadline for a request including all retries
+	openAIMaxElapsed = 60 * time.Second
+)
+
+const (
+	// DefaultOpenAIModel is the chat model used when none is specified
+	DefaultOpenAIModel = "gpt-3.5-turbo"
+	// DefaultOpenAISystemPrompt is the system prompt used for chat similarity checks when none is specified
+	DefaultOpenAISystemPrompt = "You are a helpful assistant that determines if two texts are similar in meaning."
+
+	// openAIEmbeddingModel is the model used for embedding similarity checks
+	openAIEmbeddingModel = "text-embedding-3-small"
+
+	// DefaultOpenAIPromptTokenPrice is the USD price per prompt token of DefaultOpenAIModel
+	DefaultOpenAIPromptTokenPrice = 0.5 / 1_000_000
+	// DefaultOpenAICompletionTokenPrice is the USD price per completion token of DefaultOpenAIModel
+	DefaultOpenAICompletionTokenPrice = 1.5 / 1_000_000
 )
 
+// DefaultEmbeddingThreshold is the default cosine similarity above which two texts are considered similar
+const DefaultEmbeddingThreshold = 0.8
+
 // OpenAIClient is a simple client for OpenAI API
 type OpenAIClient struct {
 	apiKey     string
 	httpClient *http.Client
+
+	// timeout and connectTimeout are set with WithTimeout and WithConnectTimeout
+	timeout        time.Duration
+	connectTimeout time.Duration
+
+	// EmbeddingThreshold is the cosine similarity above which CheckSimilarityByEmbedding considers texts similar
+	// Raise it for precision, lower it for recall
+	EmbeddingThreshold float64
+
+	// Model is the chat model used by CheckSimilarity, e.g. gpt-4o-mini
+	Model string
+	// SystemPrompt is the system message sent with every similarity check, tune it for the domain vocabulary,
+	// e.g. to treat "fix" and "resolve" as equivalent
+	SystemPrompt string
+
+	// PromptTokenPrice and CompletionTokenPrice are the USD prices per token used to estimate the cost in Usage
+	// They default to the prices of DefaultOpenAIModel, so set them when using another model
+	PromptTokenPrice     float64
+	CompletionTokenPrice float64
+
+	usageMu          sync.Mutex
+	promptTokens     int
+	completionTokens int
 }
 
-// NewOpenAIClient creates a new OpenAI client
-func NewOpenAIClient(apiKey string) *OpenAIClient {
-	return &OpenAIClient{
-		apiKey: apiKey,
-		httpClient: &http.Client{
-			Timeout: 10 * time.Second,
-		},
+var _ SimilarityChecker = (*OpenAIClient)(nil)
+
+// OpenAIOption configures an OpenAIClient
+type OpenAIOption func(*OpenAIClient)
+
+// WithModel sets the chat model, an empty model keeps the default
+func WithModel(model string) OpenAIOption {
+	return func(c *OpenAIClient) {
+		if model != "" {
+			c.Model = model
+		}
+	}
+}
+
+// WithSystemPrompt sets the system prompt, an empty prompt keeps the default
+func WithSystemPrompt(systemPrompt string) OpenAIOption {
+	return func(c *OpenAIClient) {
+		if systemPrompt != "" {
+			c.SystemPrompt = systemPrompt
+		}
+	}
+}
+
+// WithTimeout sets how long requests wait for OpenAI to start responding or send more data, 0 or less keeps httpclient.DefaultTimeout
+func WithTimeout(timeout time.Duration) OpenAIOption {
+	return func(c *OpenAIClient) {
+		if timeout > 0 {
+			c.timeout = timeout
+		}
 	}
 }
 
+// WithConnectTimeout sets how long connecting to OpenAI may take, 0 or less keeps httpclient.DefaultConnectTimeout
+func WithConnectTimeout(connectTimeout time.Duration) OpenAIOption {
+	return func(c *OpenAIClient) {
+		if connectTimeout > 0 {
+			c.connectTimeout = connectTimeout
+		}
+	}
+}
+
+// WithTokenPrices sets the USD prices per prompt and completion token used to estimate the cost
+func WithTokenPrices(promptTokenPrice, completionTokenPrice float64) OpenAIOption {
+	return func(c *OpenAIClient) {
+		c.PromptTokenPrice = promptTokenPrice
+		c.CompletionTokenPrice = completionTokenPrice
+	}
+}
+
+// NewOpenAIClient creates a new OpenAI client, using DefaultOpenAIModel and DefaultOpenAISystemPrompt
+// unless they are changed with options
+func NewOpenAIClient(apiKey string, opts ...OpenAIOption) *OpenAIClient {
+	c := &OpenAIClient{
+		apiKey:             apiKey,
+		timeout:            httpclient.DefaultTimeout,
+		connectTimeout:     httpclient.DefaultConnectTimeout,
+		EmbeddingThreshold: DefaultEmbeddingThreshold,
+		Model:              DefaultOpenAIModel,
+		SystemPrompt:       DefaultOpenAISystemPrompt,
+
+		PromptTokenPrice:     DefaultOpenAIPromptTokenPrice,
+		CompletionTokenPrice: DefaultOpenAICompletionTokenPrice,
+	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+	c.httpClient = httpclient.New(c.connectTimeout, c.timeout)
+
+	return c
+}
+
 // ChatRequest represents a request to the OpenAI Chat API
 type ChatRequest struct {
 	Model    string    `json:"model"`
@@ -45,86 +165,156 @@ type ChatResponse struct {
 			Content string `json:"content"`
 		} `json:"message"`
 	} `json:"choices"`
+	Usage TokenUsage `json:"usage"`
 	Error struct {
 		Message string `json:"message"`
 	} `json:"error"`
 }
 
+// TokenUsage is the usage object returned by the OpenAI API, embeddings only use prompt tokens
+type TokenUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+}
+
+// OpenAIUsage is the total token usage of an OpenAIClient along with its estimated cost
+type OpenAIUsage struct {
+	PromptTokens     int
+	CompletionTokens int
+	// EstimatedCost is in USD, based on the client's token prices
+	EstimatedCost float64
+}
+
+// TotalTokens returns the number of prompt and completion tokens
+func (u OpenAIUsage) TotalTokens() int {
+	return u.PromptTokens + u.CompletionTokens
+}
+
+// Usage returns the tokens used by all requests sent so far and their estimated cost
+func (c *OpenAIClient) Usage() OpenAIUsage {
+	c.usageMu.Lock()
+	defer c.usageMu.Unlock()
+
+	return OpenAIUsage{
+		PromptTokens:     c.promptTokens,
+		CompletionTokens: c.completionTokens,
+		EstimatedCost:    float64(c.promptTokens)*c.PromptTokenPrice + float64(c.completionTokens)*c.CompletionTokenPrice,
+	}
+}
+
+// addUsage adds the usage of a single response to the totals
+func (c *OpenAIClient) addUsage(usage TokenUsage) {
+	c.usageMu.Lock()
+	defer c.usageMu.Unlock()
+
+	c.promptTokens += usage.PromptTokens
+	c.completionTokens += usage.CompletionTokens
+}
+
+// SimilarityMethod identifies the chat model used by CheckSimilarity, and the system prompt if it isn't the default
+func (c *OpenAIClient) SimilarityMethod() string {
+	method := "openai-chat:" + c.Model
+	if c.SystemPrompt != DefaultOpenAISystemPrompt {
+		hash := fnv.New32a()
+		_, _ = hash.Write([]byte(c.SystemPrompt))
+		method += fmt.Sprintf("+prompt:%08x", hash.Sum32())
+	}
+
+	return method
+}
+
 // CheckSimilarity checks if two texts are similar in meaning using OpenAI API
-func (c *OpenAIClient) CheckSimilarity(text1, text2 string) (bool, error) {
+func (c *OpenAIClient) CheckSimilarity(prTitle, changelogDesc string) (bool, error) {
 	// Create request
 	chatRequest := ChatRequest{
-		Model: "gpt-3.5-turbo",
+		Model: c.Model,
 		Messages: []Message{
 			{
 				Role:    "system",
-				Content: "You are a helpful assistant that determines if two texts are similar in meaning.",
+				Content: c.SystemPrompt,
 			},
 			{
 				Role:    "user",
-				Content: fmt.Sprintf("PR Title: %s\nChangelog Description: %s\n\nAre these two texts describing the same change? Answer only YES or NO.", text1, text2),
+				Content: fmt.Sprintf("PR Title: %s\nChangelog Description: %s\n\nAre these two texts describing the same change? Answer only YES or NO.", prTitle, changelogDesc),
 			},
 		},
 	}
-	
-	// Convert to JSON
-	jsonData, err := json.Marshal(chatRequest)
-	if err != nil {
-		return false, err
-	}
-	
-	// Create HTTP request
-	req, err := http.NewRequest("POST", "https://api.openai.com/v1/chat/completions", bytes.NewBuffer(jsonData))
-	if err != nil {
-		return false, err
-	}
-	
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+c.apiKey)
-	
+
 	// Send request
-	resp, err := c.httpClient.Do(req)
-	if err != nil {
-		return false, err
-	}
-	defer resp.Body.Close()
-	
-	// Read response
-	body, err := io.ReadAll(resp.Body)
+	body, err := c.post("https://api.openai.com/v1/chat/completions", chatRequest)
 	if err != nil {
 		return false, err
 	}
-	
+
 	// Parse response
 	var chatResponse ChatResponse
 	if err := json.Unmarshal(body, &chatResponse); err != nil {
 		return false, err
 	}
-	
+	c.addUsage(chatResponse.Usage)
+
 	// Check for error
 	if chatResponse.Error.Message != "" {
 		return false, fmt.Errorf("OpenAI API error: %s", chatResponse.Error.Message)
 	}
-	
+
 	// Check if response has choices
 	if len(chatResponse.Choices) == 0 {
 		return false, fmt.Errorf("OpenAI API returned no choices")
 	}
-	
+
 	// Get answer
 	answer := chatResponse.Choices[0].Message.Content
-	
+
 	// Convert to uppercase for comparison
 	answer = strings.ToUpper(answer)
-	
+
 	// Check if answer contains YES
 	return strings.Contains(answer, "YES"), nil
 }
 
+// Explain asks for a one sentence reason the PR title and changelog description differ
+func (c *OpenAIClient) Explain(prTitle, changelogDesc string) (string, error) {
+	chatRequest := ChatRequest{
+		Model: c.Model,
+		Messages: []Message{
+			{
+				Role:    "system",
+				Content: c.SystemPrompt,
+			},
+			{
+				Role:    "user",
+				Content: fmt.Sprintf("PR Title: %s\nChangelog Description: %s\n\nIn one sentence, explain how these two texts differ in what change they describe.", prTitle, changelogDesc),
+			},
+		},
+	}
+
+	body, err := c.post("https://api.openai.com/v1/chat/completions", chatRequest)
+	if err != nil {
+		return "", err
+	}
+
+	var chatResponse ChatResponse
+	if err := json.Unmarshal(body, &chatResponse); err != nil {
+		return "", err
+	}
+	c.addUsage(chatResponse.Usage)
+
+	if chatResponse.Error.Message != "" {
+		return "", fmt.Errorf("OpenAI API error: %s", chatResponse.Error.Message)
+	}
+
+	if len(chatResponse.Choices) == 0 {
+		return "", fmt.Errorf("OpenAI API returned no choices")
+	}
+
+	return strings.TrimSpace(chatResponse.Choices[0].Message.Content), nil
+}
+
 // TestOpenAIKey tests if the OpenAI API key is valid
 func (c *OpenAIClient) TestOpenAIKey() (bool, error) {
 	chatRequest := ChatRequest{
-		Model: "gpt-3.5-turbo",
+		Model: c.Model,
 		Messages: []Message{
 			{
 				Role:    "user",
@@ -132,45 +322,198 @@ func (c *OpenAIClient) TestOpenAIKey() (bool, error) {
 			},
 		},
 	}
-	
-	// Convert to JSON
-	jsonData, err := json.Marshal(chatRequest)
+
+	// Send request
+	body, err := c.post("https://api.openai.com/v1/chat/completions", chatRequest)
 	if err != nil {
 		return false, err
 	}
-	
-	// Create HTTP request
-	req, err := http.NewRequest("POST", "https://api.openai.com/v1/chat/completions", bytes.NewBuffer(jsonData))
+
+	// Parse response
+	var chatResponse ChatResponse
+	if err := json.Unmarshal(body, &chatResponse); err != nil {
+		return false, err
+	}
+	c.addUsage(chatResponse.Usage)
+
+	// Check for error
+	if chatResponse.Error.Message != "" {
+		return false, fmt.Errorf("OpenAI API error: %s", chatResponse.Error.Message)
+	}
+
+	return true, nil
+}
+
+// OpenAIEmbeddingChecker is a SimilarityChecker that uses embeddings instead of the chat API
+type OpenAIEmbeddingChecker struct {
+	*OpenAIClient
+}
+
+var _ SimilarityChecker = OpenAIEmbeddingChecker{}
+
+// CheckSimilarity checks similarity using embeddings
+func (c OpenAIEmbeddingChecker) CheckSimilarity(prTitle, changelogDesc string) (bool, error) {
+	return c.CheckSimilarityByEmbedding(prTitle, changelogDesc)
+}
+
+// SimilarityMethod identifies the embedding model and threshold, since both decide the verdict
+func (c OpenAIEmbeddingChecker) SimilarityMethod() string {
+	return fmt.Sprintf("openai-embedding:%s@%g", openAIEmbeddingModel, c.EmbeddingThreshold)
+}
+
+// EmbeddingRequest represents a request to the OpenAI Embeddings API
+type EmbeddingRequest struct {
+	Model string   `json:"model"`
+	Input []string `json:"input"`
+}
+
+// EmbeddingResponse represents a response from the OpenAI Embeddings API
+type EmbeddingResponse struct {
+	Data []struct {
+		Embedding []float64 `json:"embedding"`
+		Index     int       `json:"index"`
+	} `json:"data"`
+	Usage TokenUsage `json:"usage"`
+	Error struct {
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// CheckSimilarityByEmbedding checks if two texts are similar in meaning by comparing their embeddings
+// The texts are similar if the cosine similarity of their embeddings exceeds EmbeddingThreshold
+func (c *OpenAIClient) CheckSimilarityByEmbedding(prTitle, changelogDesc string) (bool, error) {
+	embeddingRequest := EmbeddingRequest{
+		Model: openAIEmbeddingModel,
+		Input: []string{prTitle, changelogDesc},
+	}
+
+	// Send request
+	body, err := c.post("https://api.openai.com/v1/embeddings", embeddingRequest)
 	if err != nil {
 		return false, err
 	}
-	
+
+	// Parse response
+	var embeddingResponse EmbeddingResponse
+	if err := json.Unmarshal(body, &embeddingResponse); err != nil {
+		return false, err
+	}
+	c.addUsage(embeddingResponse.Usage)
+
+	// Check for error
+	if embeddingResponse.Error.Message != "" {
+		return false, fmt.Errorf("OpenAI API error: %s", embeddingResponse.Error.Message)
+	}
+
+	if len(embeddingResponse.Data) != 2 {
+		return false, fmt.Errorf("OpenAI API returned %d embeddings, expected 2", len(embeddingResponse.Data))
+	}
+
+	// The embeddings are not guaranteed to be returned in input order
+	embeddings := make([][]float64, 2)
+	for _, data := range embeddingResponse.Data {
+		if data.Index < 0 || data.Index > 1 {
+			return false, fmt.Errorf("OpenAI API returned unexpected embedding index %d", data.Index)
+		}
+		embeddings[data.Index] = data.Embedding
+	}
+
+	return cosineSimilarity(embeddings[0], embeddings[1]) > c.EmbeddingThreshold, nil
+}
+
+// cosineSimilarity computes the cosine similarity of two vectors
+func cosineSimilarity(a, b []float64) float64 {
+	if len(a) != len(b) || len(a) == 0 {
+		return 0
+	}
+
+	var dot, normA, normB float64
+	for i := range a {
+		dot += a[i] * b[i]
+		normA += a[i] * a[i]
+		normB += b[i] * b[i]
+	}
+
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
+
+// post sends a JSON request to the OpenAI API and returns the response body
+// Rate limits (429), server errors (5xx) and network errors are retried with exponential backoff and jitter
+func (c *OpenAIClient) post(url string, payload interface{}) ([]byte, error) {
+	jsonData, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), openAIMaxElapsed)
+	defer cancel()
+
+	var lastErr error
+	var retryAfter time.Duration
+	for attempt := 0; attempt < openAIMaxAttempts; attempt++ {
+		if attempt > 0 {
+			wait := retryAfter
+			if wait == 0 {
+				backoff := openAIBaseBackoff << (attempt - 1)
+				wait = backoff + time.Duration(rand.Int63n(int64(backoff)))
+			}
+
+			select {
+			case <-ctx.Done():
+				return nil, fmt.Errorf("OpenAI API request deadline exceeded after %d attempts: %w", attempt, lastErr)
+			case <-time.After(wait):
+			}
+		}
+
+		var body []byte
+		var retryable bool
+		body, retryable, retryAfter, err = c.postOnce(ctx, url, jsonData)
+		if err == nil || !retryable {
+			return body, err
+		}
+		lastErr = err
+	}
+
+	return nil, fmt.Errorf("OpenAI API request failed after %d attempts: %w", openAIMaxAttempts, lastErr)
+}
+
+// postOnce sends a single request, reporting whether a failure is worth retrying
+// and how long the server asked us to wait before doing so
+func (c *OpenAIClient) postOnce(ctx context.Context, url string, jsonData []byte) ([]byte, bool, time.Duration, error) {
+	// Create HTTP request
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, false, 0, err
+	}
+
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Authorization", "Bearer "+c.apiKey)
-	
+
 	// Send request
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
-		return false, err
+		return nil, ctx.Err() == nil, 0, err
 	}
 	defer resp.Body.Close()
-	
+
 	// Read response
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return false, err
+		return nil, true, 0, err
 	}
-	
-	// Parse response
-	var chatResponse ChatResponse
-	if err := json.Unmarshal(body, &chatResponse); err != nil {
-		return false, err
-	}
-	
-	// Check for error
-	if chatResponse.Error.Message != "" {
-		return false, fmt.Errorf("OpenAI API error: %s", chatResponse.Error.Message)
+
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500 {
+		var retryAfter time.Duration
+		if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && seconds > 0 {
+			retryAfter = time.Duration(seconds) * time.Second
+		}
+		return nil, true, retryAfter, fmt.Errorf("OpenAI API returned status %d", resp.StatusCode)
 	}
-	
-	return true, nil
-}
\ No newline at end of file
+
+	// Other errors are returned in the body and handled by the caller
+	return body, false, 0, nil
+}
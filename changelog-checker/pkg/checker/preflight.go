@@ -0,0 +1,159 @@
+package checker
+
+import (
+	"fmt"
+	"strings"
+)
+
+// PreflightCheck is the outcome of a single preflight check
+type PreflightCheck struct {
+	Name string
+	OK   bool
+	// Skipped is set for checks that don't apply, e.g. the OpenAI key when no OpenAI backend is configured
+	Skipped bool
+	Message string
+}
+
+func (p PreflightCheck) String() string {
+	status := "✅"
+	switch {
+	case p.Skipped:
+		status = "⏭️"
+	case !p.OK:
+		status = "❌"
+	}
+
+	return fmt.Sprintf("%s %s: %s", status, p.Name, p.Message)
+}
+
+// PreflightReport holds the outcome of every preflight check
+type PreflightReport struct {
+	Checks []PreflightCheck
+}
+
+// OK reports whether every check that ran passed
+func (r PreflightReport) OK() bool {
+	for _, check := range r.Checks {
+		if !check.OK && !check.Skipped {
+			return false
+		}
+	}
+	return true
+}
+
+func (r PreflightReport) String() string {
+	lines := make([]string, len(r.Checks))
+	for i, check := range r.Checks {
+		lines[i] = check.String()
+	}
+	return strings.Join(lines, "\n")
+}
+
+// openAIKeyTester is implemented by similarity backends whose API key can be tested, like OpenAIClient
+type openAIKeyTester interface {
+	TestOpenAIKey() (bool, error)
+}
+
+// writableCache is implemented by caches backed by a file, like db.DB
+type writableCache interface {
+	CheckWritable() error
+}
+
+// Preflight checks the environment before a run: the GitHub token, the OpenAI key, the cache database and the changelog
+// Every check runs even if an earlier one fails, so all problems are reported at once
+func (c *Checker) Preflight(changelogFile, versionTag string) PreflightReport {
+	var report PreflightReport
+	report.Checks = append(report.Checks, c.preflightGitHub())
+	report.Checks = append(report.Checks, c.preflightOpenAI())
+	report.Checks = append(report.Checks, c.preflightCache())
+	report.Checks = append(report.Checks, c.preflightChangelog(changelogFile, versionTag))
+	return report
+}
+
+func (c *Checker) preflightGitHub() PreflightCheck {
+	check := PreflightCheck{Name: "GitHub token"}
+	if c.githubClient == nil {
+		check.Skipped = true
+		check.Message = "no GitHub client configured"
+		return check
+	}
+
+	if !c.githubClient.HasToken() {
+		check.Message = "no token found, set GITHUB_TOKEN or log in with gh, unauthenticated requests are rate limited quickly"
+		return check
+	}
+
+	valid, err := c.githubClient.TestToken()
+	switch {
+	case err != nil:
+		check.Message = fmt.Sprintf("failed to test token: %v", err)
+	case !valid:
+		check.Message = fmt.Sprintf("token is invalid or can't access %s/%s", c.repoOwner, c.repoName)
+	default:
+		check.OK = true
+		check.Message = fmt.Sprintf("can access %s/%s", c.repoOwner, c.repoName)
+	}
+
+	return check
+}
+
+func (c *Checker) preflightOpenAI() PreflightCheck {
+	check := PreflightCheck{Name: "OpenAI key"}
+	tester, ok := c.similarityChecker.(openAIKeyTester)
+	if !ok {
+		check.Skipped = true
+		check.Message = "no OpenAI similarity backend configured"
+		return check
+	}
+
+	valid, err := tester.TestOpenAIKey()
+	switch {
+	case err != nil:
+		check.Message = fmt.Sprintf("failed to test key: %v", err)
+	case !valid:
+		check.Message = "key is invalid"
+	default:
+		check.OK = true
+		check.Message = "key is valid"
+	}
+
+	return check
+}
+
+func (c *Checker) preflightCache() PreflightCheck {
+	check := PreflightCheck{Name: "Cache database"}
+	if c.db == nil {
+		check.Skipped = true
+		check.Message = "validation cache disabled"
+		return check
+	}
+
+	cache, ok := c.db.(writableCache)
+	if !ok {
+		check.OK = true
+		check.Message = "in-memory cache"
+		return check
+	}
+
+	if err := cache.CheckWritable(); err != nil {
+		check.Message = fmt.Sprintf("not writable: %v", err)
+		return check
+	}
+
+	check.OK = true
+	check.Message = "writable"
+	return check
+}
+
+func (c *Checker) preflightChangelog(changelogFile, versionTag string) PreflightCheck {
+	check := PreflightCheck{Name: "Changelog"}
+	section, _, err := c.GetChangelogSection(changelogFile, versionTag)
+	if err != nil {
+		check.Message = fmt.Sprintf("failed to read %s: %v", changelogFile, err)
+		return check
+	}
+
+	check.OK = true
+	check.Message = fmt.Sprintf("%s has %d PRs to check", changelogFile, len(c.ExtractPRNumbers(c.filterSectionByComponent(section))))
+	return check
+}
@@ -0,0 +1,89 @@
+package checker
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/gjermundgaraba/changelog-checker/pkg/types"
+)
+
+// reportOrder determines where each status is placed in the report, problems first
+var reportOrder = map[types.PRStatus]int{
+	types.StatusPotentialMismatch: 0,
+	types.StatusLinkMismatch:      1,
+	types.StatusMissingPR:         2,
+	types.StatusMalformed:         3,
+	types.StatusInvalidComponent:  4,
+	types.StatusNotMerged:         5,
+	types.StatusNotInRef:          6,
+	types.StatusNotFound:          7,
+	types.StatusError:             8,
+	types.StatusDuplicate:         9,
+	types.StatusUncached:          10,
+	types.StatusGoodMatch:         11,
+}
+
+// FormatMarkdownReport formats the results as a GitHub-flavored markdown table with a summary line
+func FormatMarkdownReport(results []types.PRResult) string {
+	sorted := make([]types.PRResult, len(results))
+	copy(sorted, results)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return reportOrder[sorted[i].Status] < reportOrder[sorted[j].Status]
+	})
+
+	var sb strings.Builder
+	sb.WriteString("| PR | Status | Changelog description | PR title |\n")
+	sb.WriteString("| --- | --- | --- | --- |\n")
+	for _, result := range sorted {
+		// Entries without a PR link have no number
+		pr := "-"
+		if result.Number != 0 {
+			pr = fmt.Sprintf("#%d", result.Number)
+		}
+
+		sb.WriteString(fmt.Sprintf("| %s | %s | %s | %s |\n",
+			pr,
+			result.Status.Emoji(),
+			escapeMarkdownCell(result.ChangelogDesc),
+			escapeMarkdownCell(result.PRTitle),
+		))
+	}
+
+	sb.WriteString(fmt.Sprintf("\n%s\n", types.Summarize(results)))
+
+	return sb.String()
+}
+
+// FormatReviewList formats every result that isn't a good match as one line comparing the changelog description
+// with the PR title, e.g. `#123 | changelog: "Fix foo" | github: "fix: bar"`, problems first, for manual review
+func FormatReviewList(results []types.PRResult) string {
+	var review []types.PRResult
+	for _, result := range results {
+		if result.Status != types.StatusGoodMatch {
+			review = append(review, result)
+		}
+	}
+	sort.SliceStable(review, func(i, j int) bool {
+		return reportOrder[review[i].Status] < reportOrder[review[j].Status]
+	})
+
+	var sb strings.Builder
+	for _, result := range review {
+		// Entries without a PR link have no number
+		pr := "-"
+		if result.Number != 0 {
+			pr = fmt.Sprintf("#%d", result.Number)
+		}
+
+		sb.WriteString(fmt.Sprintf("%s %s | changelog: %q | github: %q\n", result.Status.Emoji(), pr, result.ChangelogDesc, result.PRTitle))
+	}
+
+	return sb.String()
+}
+
+// escapeMarkdownCell makes text safe to use inside a markdown table cell
+func escapeMarkdownCell(text string) string {
+	text = strings.ReplaceAll(text, "|", "\\|")
+	return strings.ReplaceAll(text, "\n", " ")
+}
@@ -0,0 +1,89 @@
+package checker
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// SimilarityChecker determines if a PR title and a changelog description describe the same change
+type SimilarityChecker interface {
+	CheckSimilarity(prTitle, changelogDesc string) (bool, error)
+}
+
+// SimilarityMethod is implemented by similarity checkers that can identify their backend and model,
+// so cached verdicts are only reused by the same method, e.g. "openai-chat:gpt-3.5-turbo"
+type SimilarityMethod interface {
+	SimilarityMethod() string
+}
+
+// similarityMethod identifies the similarity checker for the validation cache
+// Checkers that don't implement SimilarityMethod are identified by their type
+func similarityMethod(similarityChecker SimilarityChecker) string {
+	if similarityChecker == nil {
+		return "substring"
+	}
+
+	if method, ok := similarityChecker.(SimilarityMethod); ok {
+		return method.SimilarityMethod()
+	}
+
+	return fmt.Sprintf("%T", similarityChecker)
+}
+
+// SimilarityCheckerFunc adapts a function to a SimilarityChecker, e.g. to script answers in tests
+// without calling a real backend
+type SimilarityCheckerFunc func(prTitle, changelogDesc string) (bool, error)
+
+// CheckSimilarity calls f(prTitle, changelogDesc)
+func (f SimilarityCheckerFunc) CheckSimilarity(prTitle, changelogDesc string) (bool, error) {
+	return f(prTitle, changelogDesc)
+}
+
+var (
+	similarityRegistryMu sync.RWMutex
+	similarityRegistry   = map[string]func() SimilarityChecker{}
+)
+
+func init() {
+	RegisterSimilarityChecker("jaccard", func() SimilarityChecker { return NewJaccardChecker(DefaultJaccardThreshold) })
+	RegisterSimilarityChecker("levenshtein", func() SimilarityChecker { return NewLevenshteinChecker(DefaultLevenshteinThreshold) })
+	RegisterSimilarityChecker("ollama", func() SimilarityChecker { return NewOllamaClient("", "") })
+}
+
+// RegisterSimilarityChecker makes a similarity strategy available by name, e.g. for the --similarity flag
+// Registering a name again replaces the earlier factory
+func RegisterSimilarityChecker(name string, factory func() SimilarityChecker) {
+	similarityRegistryMu.Lock()
+	defer similarityRegistryMu.Unlock()
+
+	similarityRegistry[name] = factory
+}
+
+// NewSimilarityChecker creates the similarity checker registered under name
+func NewSimilarityChecker(name string) (SimilarityChecker, error) {
+	similarityRegistryMu.RLock()
+	factory, ok := similarityRegistry[name]
+	similarityRegistryMu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("unknown similarity checker %q, registered: %s", name, strings.Join(SimilarityCheckerNames(), ", "))
+	}
+
+	return factory(), nil
+}
+
+// SimilarityCheckerNames returns the names of the registered similarity checkers, sorted
+func SimilarityCheckerNames() []string {
+	similarityRegistryMu.RLock()
+	defer similarityRegistryMu.RUnlock()
+
+	names := make([]string, 0, len(similarityRegistry))
+	for name := range similarityRegistry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
@@ -0,0 +1,30 @@
+package db
+
+// Cache is the PR info and validation result cache used by the checker and the GitHub client
+// DB is the SQLite implementation, MemoryCache keeps everything in memory for one-off runs and tests
+type Cache interface {
+	// GetPRInfo retrieves PR information that hasn't expired
+	GetPRInfo(repoOwner, repoName string, prNumber int) (PRRecord, bool, error)
+	// GetStalePRInfo retrieves PR information regardless of its age
+	GetStalePRInfo(repoOwner, repoName string, prNumber int) (PRRecord, bool, error)
+	// StorePRInfo stores PR information
+	StorePRInfo(repoOwner, repoName string, prNumber int, record PRRecord) error
+	// TouchPRInfo marks cached PR information as freshly fetched without changing it
+	TouchPRInfo(repoOwner, repoName string, prNumber int) error
+
+	// GetPRDetails retrieves the full API response for a PR that hasn't expired
+	GetPRDetails(repoOwner, repoName string, prNumber int) ([]byte, bool, error)
+	// StorePRDetails stores the full API response for a PR
+	StorePRDetails(repoOwner, repoName string, prNumber int, data []byte) error
+
+	// GetValidationResult retrieves a validation result for an unchanged changelog description and similarity method
+	// that hasn't expired
+	GetValidationResult(repoOwner, repoName string, prNumber int, changelogDesc, method string) (int, bool, error)
+	// StoreValidationResult stores a validation result along with the similarity method that produced it
+	StoreValidationResult(repoOwner, repoName string, prNumber int, changelogDesc, method string, status int) error
+}
+
+var (
+	_ Cache = (*DB)(nil)
+	_ Cache = (*MemoryCache)(nil)
+)
@@ -2,20 +2,72 @@ package db
 
 import (
 	"database/sql"
-	"log"
+	"fmt"
+	"log/slog"
 	"os"
 	"path/filepath"
+	"sync"
 	"time"
 
 	_ "github.com/mattn/go-sqlite3"
 )
 
+// DefaultTTL is how long cached PR info and validation results are used before they are refreshed
+const DefaultTTL = 7 * 24 * time.Hour
+
 type DB struct {
-	db *sql.DB
+	db     *sql.DB
+	logger *slog.Logger
+	ttl    time.Duration
+
+	// First lookup result per PR during this process, used for Stats
+	statsMu           sync.Mutex
+	prLookups         map[string]lookupResult
+	detailLookups     map[string]lookupResult
+	validationLookups map[string]lookupResult
+}
+
+// lookupResult is the outcome of a cache lookup
+type lookupResult int
+
+const (
+	lookupHit lookupResult = iota
+	lookupMiss
+	lookupExpired
+)
+
+// CacheStats counts the PRs that were served from a cache, missing from it, or in it but expired
+type CacheStats struct {
+	Hits    int
+	Misses  int
+	Expired int
+}
+
+// Stats is the cache usage accumulated during the process
+type Stats struct {
+	PRs         CacheStats
+	Details     CacheStats
+	Validations CacheStats
 }
 
-// NewDB creates a new SQLite database for caching GitHub API calls
-func NewDB() (*DB, error) {
+// NewDB creates a new SQLite database for caching GitHub API calls, using DefaultTTL
+// A nil logger defaults to a text handler on stderr
+func NewDB(logger *slog.Logger) (*DB, error) {
+	return NewDBWithTTL(logger, DefaultTTL)
+}
+
+// NewDBWithTTL creates a new SQLite database for caching GitHub API calls
+// Cached entries older than ttl are refreshed. PR titles of merged PRs basically never change,
+// so a long TTL (e.g. 90 days) is safe for a released repo and saves a lot of API calls
+func NewDBWithTTL(logger *slog.Logger, ttl time.Duration) (*DB, error) {
+	if ttl <= 0 {
+		return nil, fmt.Errorf("cache TTL must be positive, got %s", ttl)
+	}
+
+	if logger == nil {
+		logger = slog.New(slog.NewTextHandler(os.Stderr, nil))
+	}
+
 	// Create cache directory if it doesn't exist
 	cacheDir := filepath.Join(os.Getenv("HOME"), ".changelog-checker")
 	if err := os.MkdirAll(cacheDir, 0755); err != nil {
@@ -36,13 +88,50 @@ func NewDB() (*DB, error) {
 			pr_number INTEGER,
 			title TEXT,
 			fetched_at TIMESTAMP,
+			etag TEXT,
+			state TEXT,
+			merged INTEGER,
+			not_found INTEGER,
+			issue INTEGER,
+			PRIMARY KEY (repo_owner, repo_name, pr_number)
+		)
+	`)
+	if err != nil {
+		return nil, err
+	}
+
+	// Older cache databases were created before these columns existed
+	if err := addColumnIfMissing(db, "github_pr_cache", "etag", "TEXT"); err != nil {
+		return nil, err
+	}
+	if err := addColumnIfMissing(db, "github_pr_cache", "state", "TEXT"); err != nil {
+		return nil, err
+	}
+	if err := addColumnIfMissing(db, "github_pr_cache", "merged", "INTEGER"); err != nil {
+		return nil, err
+	}
+	if err := addColumnIfMissing(db, "github_pr_cache", "not_found", "INTEGER"); err != nil {
+		return nil, err
+	}
+	if err := addColumnIfMissing(db, "github_pr_cache", "issue", "INTEGER"); err != nil {
+		return nil, err
+	}
+
+	// Create PR details table, holding the full API response for checks that need more than the title
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS github_pr_details (
+			repo_owner TEXT,
+			repo_name TEXT,
+			pr_number INTEGER,
+			data TEXT,
+			fetched_at TIMESTAMP,
 			PRIMARY KEY (repo_owner, repo_name, pr_number)
 		)
 	`)
 	if err != nil {
 		return nil, err
 	}
-	
+
 	// Create validation cache table
 	_, err = db.Exec(`
 		CREATE TABLE IF NOT EXISTS validation_cache (
@@ -52,6 +141,7 @@ func NewDB() (*DB, error) {
 			changelog_desc TEXT,
 			status INTEGER,
 			last_validated TIMESTAMP,
+			method TEXT,
 			PRIMARY KEY (repo_owner, repo_name, pr_number)
 		)
 	`)
@@ -59,7 +149,55 @@ func NewDB() (*DB, error) {
 		return nil, err
 	}
 
-	return &DB{db: db}, nil
+	// Verdicts cached before the method was tracked have a NULL method, so they are never reused
+	if err := addColumnIfMissing(db, "validation_cache", "method", "TEXT"); err != nil {
+		return nil, err
+	}
+
+	// Create validation run history table
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS validation_runs (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			repo_owner TEXT,
+			repo_name TEXT,
+			version_tag TEXT,
+			run_at TIMESTAMP,
+			good_matches INTEGER,
+			potential_mismatches INTEGER,
+			not_found INTEGER
+		)
+	`)
+	if err != nil {
+		return nil, err
+	}
+
+	return &DB{
+		db:                db,
+		logger:            logger,
+		ttl:               ttl,
+		prLookups:         make(map[string]lookupResult),
+		detailLookups:     make(map[string]lookupResult),
+		validationLookups: make(map[string]lookupResult),
+	}, nil
+}
+
+// addColumnIfMissing adds a column to an existing table if it isn't there yet
+func addColumnIfMissing(db *sql.DB, table, column, columnType string) error {
+	var count int
+	err := db.QueryRow(
+		"SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = ?",
+		table, column,
+	).Scan(&count)
+	if err != nil {
+		return err
+	}
+
+	if count > 0 {
+		return nil
+	}
+
+	_, err = db.Exec("ALTER TABLE " + table + " ADD COLUMN " + column + " " + columnType)
+	return err
 }
 
 // Close closes the database connection
@@ -67,77 +205,295 @@ func (d *DB) Close() error {
 	return d.db.Close()
 }
 
+// CheckWritable checks that the database file can be written, by creating a table in a transaction that is rolled back
+func (d *DB) CheckWritable() error {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	_, err = tx.Exec("CREATE TABLE preflight_check (id INTEGER)")
+	return err
+}
+
+// Stats returns the cache hits, misses and expired entries seen so far
+// Each PR is counted once, by the result of its first lookup, since the same PR is usually looked up
+// again right after it has been fetched and stored
+func (d *DB) Stats() Stats {
+	d.statsMu.Lock()
+	defer d.statsMu.Unlock()
+
+	return Stats{
+		PRs:         countLookups(d.prLookups),
+		Details:     countLookups(d.detailLookups),
+		Validations: countLookups(d.validationLookups),
+	}
+}
+
+// recordLookup records the result of a cache lookup unless the PR has been looked up before
+func (d *DB) recordLookup(lookups map[string]lookupResult, repoOwner, repoName string, prNumber int, result lookupResult) {
+	d.statsMu.Lock()
+	defer d.statsMu.Unlock()
+
+	key := fmt.Sprintf("%s/%s#%d", repoOwner, repoName, prNumber)
+	if _, ok := lookups[key]; !ok {
+		lookups[key] = result
+	}
+}
+
+func countLookups(lookups map[string]lookupResult) CacheStats {
+	var stats CacheStats
+	for _, result := range lookups {
+		switch result {
+		case lookupHit:
+			stats.Hits++
+		case lookupMiss:
+			stats.Misses++
+		case lookupExpired:
+			stats.Expired++
+		}
+	}
+
+	return stats
+}
+
+// Prune deletes cached PR info, PR details and validation results older than olderThan and reclaims the space
+// Returns the number of rows removed
+func (d *DB) Prune(olderThan time.Duration) (int, error) {
+	cutoff := time.Now().Add(-olderThan)
+
+	var removed int64
+	for _, query := range []string{
+		"DELETE FROM github_pr_cache WHERE fetched_at < ?",
+		"DELETE FROM github_pr_details WHERE fetched_at < ?",
+		"DELETE FROM validation_cache WHERE last_validated < ?",
+	} {
+		result, err := d.db.Exec(query, cutoff)
+		if err != nil {
+			return 0, err
+		}
+		rows, err := result.RowsAffected()
+		if err != nil {
+			return 0, err
+		}
+		removed += rows
+	}
+
+	// Deleting rows doesn't shrink the file, VACUUM rebuilds it without the free pages
+	if _, err := d.db.Exec("VACUUM"); err != nil {
+		return 0, err
+	}
+
+	return int(removed), nil
+}
+
+// PRRecord is the cached information about a PR
+type PRRecord struct {
+	Title  string
+	State  string // "open" or "closed", empty for entries cached before state was tracked
+	Merged bool
+	ETag   string
+
+	// NotFound marks a PR that GitHub returned 404 for, so it isn't refetched until the cache expires
+	NotFound bool
+
+	// Issue marks a number that refers to an issue rather than a PR, issues are never merged
+	Issue bool
+}
+
 // GetPRInfo retrieves PR information from the cache
-func (d *DB) GetPRInfo(repoOwner, repoName string, prNumber int) (string, bool, error) {
-	var title string
+func (d *DB) GetPRInfo(repoOwner, repoName string, prNumber int) (PRRecord, bool, error) {
+	record, fetchedAt, found, err := d.getPRRecord(repoOwner, repoName, prNumber)
+	if err != nil {
+		return PRRecord{}, false, err
+	}
+	if !found {
+		d.recordLookup(d.prLookups, repoOwner, repoName, prNumber, lookupMiss)
+		return PRRecord{}, false, nil
+	}
+
+	// Check if cache is older than the TTL
+	if time.Since(fetchedAt) > d.ttl {
+		d.logger.Debug("PR cache is older than the TTL, will refresh", "pr", prNumber, "ttl", d.ttl)
+		d.recordLookup(d.prLookups, repoOwner, repoName, prNumber, lookupExpired)
+		return PRRecord{}, false, nil
+	}
+
+	d.recordLookup(d.prLookups, repoOwner, repoName, prNumber, lookupHit)
+	return record, true, nil
+}
+
+// GetStalePRInfo retrieves PR information from the cache regardless of its age
+func (d *DB) GetStalePRInfo(repoOwner, repoName string, prNumber int) (PRRecord, bool, error) {
+	record, _, found, err := d.getPRRecord(repoOwner, repoName, prNumber)
+	return record, found, err
+}
+
+// getPRRecord reads a PR cache row along with when it was fetched
+func (d *DB) getPRRecord(repoOwner, repoName string, prNumber int) (PRRecord, time.Time, bool, error) {
+	var record PRRecord
+	var fetchedAt time.Time
+
+	err := d.db.QueryRow(
+		"SELECT title, COALESCE(state, ''), COALESCE(merged, 0), COALESCE(etag, ''), COALESCE(not_found, 0), COALESCE(issue, 0), fetched_at FROM github_pr_cache WHERE repo_owner = ? AND repo_name = ? AND pr_number = ?",
+		repoOwner, repoName, prNumber,
+	).Scan(&record.Title, &record.State, &record.Merged, &record.ETag, &record.NotFound, &record.Issue, &fetchedAt)
+
+	if err == sql.ErrNoRows {
+		return PRRecord{}, time.Time{}, false, nil
+	} else if err != nil {
+		return PRRecord{}, time.Time{}, false, err
+	}
+
+	return record, fetchedAt, true, nil
+}
+
+// StorePRInfo stores PR information in the cache
+func (d *DB) StorePRInfo(repoOwner, repoName string, prNumber int, record PRRecord) error {
+	_, err := d.db.Exec(
+		"INSERT OR REPLACE INTO github_pr_cache (repo_owner, repo_name, pr_number, title, fetched_at, etag, state, merged, not_found, issue) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		repoOwner, repoName, prNumber, record.Title, time.Now(), record.ETag, record.State, record.Merged, record.NotFound, record.Issue,
+	)
+	return err
+}
+
+// TouchPRInfo marks cached PR information as freshly fetched without changing it
+func (d *DB) TouchPRInfo(repoOwner, repoName string, prNumber int) error {
+	_, err := d.db.Exec(
+		"UPDATE github_pr_cache SET fetched_at = ? WHERE repo_owner = ? AND repo_name = ? AND pr_number = ?",
+		time.Now(), repoOwner, repoName, prNumber,
+	)
+	return err
+}
+
+// GetPRDetails retrieves the full API response for a PR from the cache
+func (d *DB) GetPRDetails(repoOwner, repoName string, prNumber int) ([]byte, bool, error) {
+	var data string
 	var fetchedAt time.Time
 
 	err := d.db.QueryRow(
-		"SELECT title, fetched_at FROM github_pr_cache WHERE repo_owner = ? AND repo_name = ? AND pr_number = ?",
+		"SELECT data, fetched_at FROM github_pr_details WHERE repo_owner = ? AND repo_name = ? AND pr_number = ?",
 		repoOwner, repoName, prNumber,
-	).Scan(&title, &fetchedAt)
+	).Scan(&data, &fetchedAt)
 
 	if err == sql.ErrNoRows {
-		return "", false, nil
+		d.recordLookup(d.detailLookups, repoOwner, repoName, prNumber, lookupMiss)
+		return nil, false, nil
 	} else if err != nil {
-		return "", false, err
+		return nil, false, err
 	}
 
-	// Check if cache is older than 7 days
-	if time.Since(fetchedAt) > 7*24*time.Hour {
-		log.Printf("Cache for PR #%d is older than 7 days, will refresh", prNumber)
-		return "", false, nil
+	if time.Since(fetchedAt) > d.ttl {
+		d.logger.Debug("PR details cache is older than the TTL, will refresh", "pr", prNumber, "ttl", d.ttl)
+		d.recordLookup(d.detailLookups, repoOwner, repoName, prNumber, lookupExpired)
+		return nil, false, nil
 	}
 
-	return title, true, nil
+	d.recordLookup(d.detailLookups, repoOwner, repoName, prNumber, lookupHit)
+	return []byte(data), true, nil
 }
 
-// StorePRInfo stores PR information in the cache
-func (d *DB) StorePRInfo(repoOwner, repoName string, prNumber int, title string) error {
+// StorePRDetails stores the full API response for a PR in the cache
+func (d *DB) StorePRDetails(repoOwner, repoName string, prNumber int, data []byte) error {
 	_, err := d.db.Exec(
-		"INSERT OR REPLACE INTO github_pr_cache (repo_owner, repo_name, pr_number, title, fetched_at) VALUES (?, ?, ?, ?, ?)",
-		repoOwner, repoName, prNumber, title, time.Now(),
+		"INSERT OR REPLACE INTO github_pr_details (repo_owner, repo_name, pr_number, data, fetched_at) VALUES (?, ?, ?, ?, ?)",
+		repoOwner, repoName, prNumber, string(data), time.Now(),
 	)
 	return err
 }
 
 // GetValidationResult retrieves validation result from the cache
+// The method identifies the similarity backend and model, results from a different method are a cache miss
 // Returns status, cached (bool), and error
-func (d *DB) GetValidationResult(repoOwner, repoName string, prNumber int, changelogDesc string) (int, bool, error) {
+func (d *DB) GetValidationResult(repoOwner, repoName string, prNumber int, changelogDesc, method string) (int, bool, error) {
 	var status int
 	var storedChangelogDesc string
+	var storedMethod string
 	var lastValidated time.Time
 
 	err := d.db.QueryRow(
-		"SELECT changelog_desc, status, last_validated FROM validation_cache WHERE repo_owner = ? AND repo_name = ? AND pr_number = ?",
+		"SELECT changelog_desc, status, last_validated, COALESCE(method, '') FROM validation_cache WHERE repo_owner = ? AND repo_name = ? AND pr_number = ?",
 		repoOwner, repoName, prNumber,
-	).Scan(&storedChangelogDesc, &status, &lastValidated)
+	).Scan(&storedChangelogDesc, &status, &lastValidated, &storedMethod)
 
 	if err == sql.ErrNoRows {
+		d.recordLookup(d.validationLookups, repoOwner, repoName, prNumber, lookupMiss)
 		return 0, false, nil
 	} else if err != nil {
 		return 0, false, err
 	}
 
-	// If the changelog description has changed, invalidate the cache
-	if storedChangelogDesc != changelogDesc {
+	// If the changelog description or similarity method has changed, invalidate the cache
+	if storedChangelogDesc != changelogDesc || storedMethod != method {
+		d.recordLookup(d.validationLookups, repoOwner, repoName, prNumber, lookupMiss)
 		return 0, false, nil
 	}
 
-	// Check if cache is older than 7 days (same as PR info cache)
-	if time.Since(lastValidated) > 7*24*time.Hour {
-		log.Printf("Validation cache for PR #%d is older than 7 days, will refresh", prNumber)
+	// Check if cache is older than the TTL (same as PR info cache)
+	if time.Since(lastValidated) > d.ttl {
+		d.logger.Debug("Validation cache is older than the TTL, will refresh", "pr", prNumber, "ttl", d.ttl)
+		d.recordLookup(d.validationLookups, repoOwner, repoName, prNumber, lookupExpired)
 		return 0, false, nil
 	}
 
+	d.recordLookup(d.validationLookups, repoOwner, repoName, prNumber, lookupHit)
 	return status, true, nil
 }
 
 // StoreValidationResult stores validation result in the cache
-func (d *DB) StoreValidationResult(repoOwner, repoName string, prNumber int, changelogDesc string, status int) error {
+func (d *DB) StoreValidationResult(repoOwner, repoName string, prNumber int, changelogDesc, method string, status int) error {
 	_, err := d.db.Exec(
-		"INSERT OR REPLACE INTO validation_cache (repo_owner, repo_name, pr_number, changelog_desc, status, last_validated) VALUES (?, ?, ?, ?, ?, ?)",
-		repoOwner, repoName, prNumber, changelogDesc, status, time.Now(),
+		"INSERT OR REPLACE INTO validation_cache (repo_owner, repo_name, pr_number, changelog_desc, status, last_validated, method) VALUES (?, ?, ?, ?, ?, ?, ?)",
+		repoOwner, repoName, prNumber, changelogDesc, status, time.Now(), method,
 	)
 	return err
-}
\ No newline at end of file
+}
+
+// RunSummary is the summary of a single validation run
+type RunSummary struct {
+	RepoOwner           string
+	RepoName            string
+	VersionTag          string
+	RunAt               time.Time
+	GoodMatches         int
+	PotentialMismatches int
+	NotFound            int
+}
+
+// StoreRunSummary records a validation run in the history
+// RunAt defaults to now if not set
+func (d *DB) StoreRunSummary(summary RunSummary) error {
+	if summary.RunAt.IsZero() {
+		summary.RunAt = time.Now()
+	}
+
+	_, err := d.db.Exec(
+		"INSERT INTO validation_runs (repo_owner, repo_name, version_tag, run_at, good_matches, potential_mismatches, not_found) VALUES (?, ?, ?, ?, ?, ?, ?)",
+		summary.RepoOwner, summary.RepoName, summary.VersionTag, summary.RunAt, summary.GoodMatches, summary.PotentialMismatches, summary.NotFound,
+	)
+	return err
+}
+
+// GetRunHistory retrieves all recorded validation runs for a repo, oldest first
+func (d *DB) GetRunHistory(repoOwner, repoName string) ([]RunSummary, error) {
+	rows, err := d.db.Query(
+		"SELECT repo_owner, repo_name, version_tag, run_at, good_matches, potential_mismatches, not_found FROM validation_runs WHERE repo_owner = ? AND repo_name = ? ORDER BY run_at, id",
+		repoOwner, repoName,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var history []RunSummary
+	for rows.Next() {
+		var summary RunSummary
+		if err := rows.Scan(&summary.RepoOwner, &summary.RepoName, &summary.VersionTag, &summary.RunAt, &summary.GoodMatches, &summary.PotentialMismatches, &summary.NotFound); err != nil {
+			return nil, err
+		}
+		history = append(history, summary)
+	}
+
+	return history, rows.Err()
+}
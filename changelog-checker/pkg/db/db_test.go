@@ -0,0 +1,141 @@
+package db
+
+import (
+	"bytes"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+// newTestDB opens a cache database in a temporary home directory
+func newTestDB(t *testing.T, ttl time.Duration) *DB {
+	t.Helper()
+
+	t.Setenv("HOME", t.TempDir())
+	database, err := NewDBWithTTL(slog.New(slog.NewTextHandler(io.Discard, nil)), ttl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { database.Close() })
+
+	return database
+}
+
+// countRows returns the number of rows in the table
+func countRows(t *testing.T, d *DB, table string) int {
+	t.Helper()
+
+	var count int
+	if err := d.db.QueryRow("SELECT COUNT(*) FROM " + table).Scan(&count); err != nil {
+		t.Fatal(err)
+	}
+	return count
+}
+
+func TestPrune(t *testing.T) {
+	d := newTestDB(t, DefaultTTL)
+	old := time.Now().Add(-48 * time.Hour)
+
+	for prNumber := 1; prNumber <= 2; prNumber++ {
+		if err := d.StorePRInfo("o", "r", prNumber, PRRecord{Title: "title", State: "closed"}); err != nil {
+			t.Fatal(err)
+		}
+		if err := d.StorePRDetails("o", "r", prNumber, []byte(`{"title": "title"}`)); err != nil {
+			t.Fatal(err)
+		}
+		if err := d.StoreValidationResult("o", "r", prNumber, "desc", "substring", 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Age PR 1 in every table
+	for _, query := range []string{
+		"UPDATE github_pr_cache SET fetched_at = ? WHERE pr_number = 1",
+		"UPDATE github_pr_details SET fetched_at = ? WHERE pr_number = 1",
+		"UPDATE validation_cache SET last_validated = ? WHERE pr_number = 1",
+	} {
+		if _, err := d.db.Exec(query, old); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	removed, err := d.Prune(24 * time.Hour)
+	if err != nil {
+		t.Fatalf("Prune() error = %v", err)
+	}
+	if removed != 3 {
+		t.Errorf("Prune() removed %d rows, want 3", removed)
+	}
+
+	for _, table := range []string{"github_pr_cache", "github_pr_details", "validation_cache"} {
+		if count := countRows(t, d, table); count != 1 {
+			t.Errorf("%s has %d rows after pruning, want 1", table, count)
+		}
+	}
+}
+
+func TestStatsCountsDetails(t *testing.T) {
+	d := newTestDB(t, time.Hour)
+
+	if err := d.StorePRDetails("o", "r", 1, []byte(`{}`)); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.StorePRDetails("o", "r", 2, []byte(`{}`)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.db.Exec("UPDATE github_pr_details SET fetched_at = ? WHERE pr_number = 2", time.Now().Add(-2*time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, prNumber := range []int{1, 2, 3, 1} {
+		if _, _, err := d.GetPRDetails("o", "r", prNumber); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want := CacheStats{Hits: 1, Misses: 1, Expired: 1}
+	if got := d.Stats().Details; got != want {
+		t.Errorf("Stats().Details = %+v, want %+v", got, want)
+	}
+	if got := d.Stats().PRs; got != (CacheStats{}) {
+		t.Errorf("Stats().PRs = %+v, want no lookups", got)
+	}
+}
+
+func TestExportImportKeepsDetails(t *testing.T) {
+	source := newTestDB(t, time.Hour)
+	if err := source.StorePRInfo("o", "r", 1, PRRecord{Title: "title", State: "closed", Merged: true}); err != nil {
+		t.Fatal(err)
+	}
+	if err := source.StorePRDetails("o", "r", 1, []byte(`{"title":"title","merge_commit_sha":"abc"}`)); err != nil {
+		t.Fatal(err)
+	}
+	if err := source.StoreValidationResult("o", "r", 1, "desc", "substring", 0); err != nil {
+		t.Fatal(err)
+	}
+
+	var export bytes.Buffer
+	if err := source.Export(&export); err != nil {
+		t.Fatalf("Export() error = %v", err)
+	}
+
+	target := newTestDB(t, time.Hour)
+	if err := target.Import(&export); err != nil {
+		t.Fatalf("Import() error = %v", err)
+	}
+
+	for _, table := range []string{"github_pr_cache", "github_pr_details", "validation_cache"} {
+		if count := countRows(t, target, table); count != 1 {
+			t.Errorf("%s has %d rows after importing, want 1", table, count)
+		}
+	}
+
+	data, found, err := target.GetPRDetails("o", "r", 1)
+	if err != nil || !found {
+		t.Fatalf("GetPRDetails() = %v, %v, want the imported details", found, err)
+	}
+	if string(data) != `{"title":"title","merge_commit_sha":"abc"}` {
+		t.Errorf("GetPRDetails() = %s, want the exported data", data)
+	}
+}
@@ -0,0 +1,178 @@
+package db
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+)
+
+// exportVersion is bumped when the export format changes, Import rejects other versions
+const exportVersion = 1
+
+// cacheExport is the JSON document written by Export and read by Import
+type cacheExport struct {
+	Version     int                  `json:"version"`
+	PRs         []exportedPR         `json:"github_pr_cache"`
+	Details     []exportedPRDetails  `json:"github_pr_details"`
+	Validations []exportedValidation `json:"validation_cache"`
+}
+
+// exportedPR is a github_pr_cache row
+type exportedPR struct {
+	RepoOwner string    `json:"repo_owner"`
+	RepoName  string    `json:"repo_name"`
+	PRNumber  int       `json:"pr_number"`
+	Title     string    `json:"title"`
+	FetchedAt time.Time `json:"fetched_at"`
+	ETag      string    `json:"etag,omitempty"`
+	State     string    `json:"state,omitempty"`
+	Merged    bool      `json:"merged,omitempty"`
+	NotFound  bool      `json:"not_found,omitempty"`
+	Issue     bool      `json:"issue,omitempty"`
+}
+
+// exportedPRDetails is a github_pr_details row, Data is the full API response as stored
+type exportedPRDetails struct {
+	RepoOwner string          `json:"repo_owner"`
+	RepoName  string          `json:"repo_name"`
+	PRNumber  int             `json:"pr_number"`
+	Data      json.RawMessage `json:"data"`
+	FetchedAt time.Time       `json:"fetched_at"`
+}
+
+// exportedValidation is a validation_cache row
+type exportedValidation struct {
+	RepoOwner     string    `json:"repo_owner"`
+	RepoName      string    `json:"repo_name"`
+	PRNumber      int       `json:"pr_number"`
+	ChangelogDesc string    `json:"changelog_desc"`
+	Status        int       `json:"status"`
+	LastValidated time.Time `json:"last_validated"`
+	Method        string    `json:"method,omitempty"`
+}
+
+// Export writes the cached PR info, PR details and validation results as JSON, e.g. to seed the cache of ephemeral CI machines
+// Timestamps are kept, so imported entries expire as they would have in the exporting cache
+func (d *DB) Export(w io.Writer) error {
+	export := cacheExport{
+		Version:     exportVersion,
+		PRs:         []exportedPR{},
+		Details:     []exportedPRDetails{},
+		Validations: []exportedValidation{},
+	}
+
+	prRows, err := d.db.Query("SELECT repo_owner, repo_name, pr_number, COALESCE(title, ''), fetched_at, COALESCE(etag, ''), COALESCE(state, ''), COALESCE(merged, 0), COALESCE(not_found, 0), COALESCE(issue, 0) FROM github_pr_cache ORDER BY repo_owner, repo_name, pr_number")
+	if err != nil {
+		return err
+	}
+	defer prRows.Close()
+
+	for prRows.Next() {
+		var pr exportedPR
+		if err := prRows.Scan(&pr.RepoOwner, &pr.RepoName, &pr.PRNumber, &pr.Title, &pr.FetchedAt, &pr.ETag, &pr.State, &pr.Merged, &pr.NotFound, &pr.Issue); err != nil {
+			return err
+		}
+		export.PRs = append(export.PRs, pr)
+	}
+	if err := prRows.Err(); err != nil {
+		return err
+	}
+
+	detailRows, err := d.db.Query("SELECT repo_owner, repo_name, pr_number, COALESCE(data, ''), fetched_at FROM github_pr_details ORDER BY repo_owner, repo_name, pr_number")
+	if err != nil {
+		return err
+	}
+	defer detailRows.Close()
+
+	for detailRows.Next() {
+		var details exportedPRDetails
+		var data string
+		if err := detailRows.Scan(&details.RepoOwner, &details.RepoName, &details.PRNumber, &data, &details.FetchedAt); err != nil {
+			return err
+		}
+		// Rows that aren't valid JSON can't be embedded, they are fetched again instead
+		if !json.Valid([]byte(data)) {
+			continue
+		}
+		details.Data = json.RawMessage(data)
+		export.Details = append(export.Details, details)
+	}
+	if err := detailRows.Err(); err != nil {
+		return err
+	}
+
+	validationRows, err := d.db.Query("SELECT repo_owner, repo_name, pr_number, COALESCE(changelog_desc, ''), status, last_validated, COALESCE(method, '') FROM validation_cache ORDER BY repo_owner, repo_name, pr_number")
+	if err != nil {
+		return err
+	}
+	defer validationRows.Close()
+
+	for validationRows.Next() {
+		var validation exportedValidation
+		if err := validationRows.Scan(&validation.RepoOwner, &validation.RepoName, &validation.PRNumber, &validation.ChangelogDesc, &validation.Status, &validation.LastValidated, &validation.Method); err != nil {
+			return err
+		}
+		export.Validations = append(export.Validations, validation)
+	}
+	if err := validationRows.Err(); err != nil {
+		return err
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(export)
+}
+
+// Import loads PR info, PR details and validation results written by Export, replacing cached entries for the same PRs
+// Everything is imported in a single transaction, so a malformed file leaves the cache untouched
+func (d *DB) Import(r io.Reader) error {
+	var export cacheExport
+	if err := json.NewDecoder(r).Decode(&export); err != nil {
+		return fmt.Errorf("failed to decode cache export: %w", err)
+	}
+	if export.Version != exportVersion {
+		return fmt.Errorf("unsupported cache export version %d, expected %d", export.Version, exportVersion)
+	}
+
+	tx, err := d.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, pr := range export.PRs {
+		if _, err := tx.Exec(
+			"INSERT OR REPLACE INTO github_pr_cache (repo_owner, repo_name, pr_number, title, fetched_at, etag, state, merged, not_found, issue) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+			pr.RepoOwner, pr.RepoName, pr.PRNumber, pr.Title, pr.FetchedAt, pr.ETag, pr.State, pr.Merged, pr.NotFound, pr.Issue,
+		); err != nil {
+			return fmt.Errorf("failed to import PR #%d of %s/%s: %w", pr.PRNumber, pr.RepoOwner, pr.RepoName, err)
+		}
+	}
+
+	for _, details := range export.Details {
+		// The export is indented, store the data compact like the API client does
+		var data bytes.Buffer
+		if err := json.Compact(&data, details.Data); err != nil {
+			return fmt.Errorf("failed to import details of PR #%d of %s/%s: %w", details.PRNumber, details.RepoOwner, details.RepoName, err)
+		}
+		if _, err := tx.Exec(
+			"INSERT OR REPLACE INTO github_pr_details (repo_owner, repo_name, pr_number, data, fetched_at) VALUES (?, ?, ?, ?, ?)",
+			details.RepoOwner, details.RepoName, details.PRNumber, data.String(), details.FetchedAt,
+		); err != nil {
+			return fmt.Errorf("failed to import details of PR #%d of %s/%s: %w", details.PRNumber, details.RepoOwner, details.RepoName, err)
+		}
+	}
+
+	for _, validation := range export.Validations {
+		if _, err := tx.Exec(
+			"INSERT OR REPLACE INTO validation_cache (repo_owner, repo_name, pr_number, changelog_desc, status, last_validated, method) VALUES (?, ?, ?, ?, ?, ?, ?)",
+			validation.RepoOwner, validation.RepoName, validation.PRNumber, validation.ChangelogDesc, validation.Status, validation.LastValidated, validation.Method,
+		); err != nil {
+			return fmt.Errorf("failed to import validation result for PR #%d of %s/%s: %w", validation.PRNumber, validation.RepoOwner, validation.RepoName, err)
+		}
+	}
+
+	return tx.Commit()
+}
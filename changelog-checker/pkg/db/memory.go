@@ -0,0 +1,148 @@
+package db
+
+import (
+	"sync"
+	"time"
+)
+
+// prKey identifies a PR in the memory cache
+type prKey struct {
+	repoOwner string
+	repoName  string
+	prNumber  int
+}
+
+type memoryPR struct {
+	record    PRRecord
+	fetchedAt time.Time
+}
+
+type memoryDetails struct {
+	data      []byte
+	fetchedAt time.Time
+}
+
+type memoryValidation struct {
+	changelogDesc string
+	method        string
+	status        int
+	lastValidated time.Time
+}
+
+// MemoryCache is an in-memory Cache, so one-off runs and tests don't need a SQLite file
+type MemoryCache struct {
+	mu          sync.Mutex
+	ttl         time.Duration
+	prs         map[prKey]memoryPR
+	details     map[prKey]memoryDetails
+	validations map[prKey]memoryValidation
+}
+
+// NewMemoryCache creates an empty in-memory cache whose entries expire after ttl, 0 or less uses DefaultTTL
+func NewMemoryCache(ttl time.Duration) *MemoryCache {
+	if ttl <= 0 {
+		ttl = DefaultTTL
+	}
+
+	return &MemoryCache{
+		ttl:         ttl,
+		prs:         make(map[prKey]memoryPR),
+		details:     make(map[prKey]memoryDetails),
+		validations: make(map[prKey]memoryValidation),
+	}
+}
+
+// GetPRInfo retrieves PR information from the cache
+func (m *MemoryCache) GetPRInfo(repoOwner, repoName string, prNumber int) (PRRecord, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	pr, found := m.prs[prKey{repoOwner, repoName, prNumber}]
+	if !found || time.Since(pr.fetchedAt) > m.ttl {
+		return PRRecord{}, false, nil
+	}
+
+	return pr.record, true, nil
+}
+
+// GetStalePRInfo retrieves PR information from the cache regardless of its age
+func (m *MemoryCache) GetStalePRInfo(repoOwner, repoName string, prNumber int) (PRRecord, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	pr, found := m.prs[prKey{repoOwner, repoName, prNumber}]
+	return pr.record, found, nil
+}
+
+// StorePRInfo stores PR information in the cache
+func (m *MemoryCache) StorePRInfo(repoOwner, repoName string, prNumber int, record PRRecord) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.prs[prKey{repoOwner, repoName, prNumber}] = memoryPR{record: record, fetchedAt: time.Now()}
+	return nil
+}
+
+// TouchPRInfo marks cached PR information as freshly fetched without changing it
+func (m *MemoryCache) TouchPRInfo(repoOwner, repoName string, prNumber int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := prKey{repoOwner, repoName, prNumber}
+	if pr, found := m.prs[key]; found {
+		pr.fetchedAt = time.Now()
+		m.prs[key] = pr
+	}
+
+	return nil
+}
+
+// GetPRDetails retrieves the full API response for a PR from the cache
+func (m *MemoryCache) GetPRDetails(repoOwner, repoName string, prNumber int) ([]byte, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	details, found := m.details[prKey{repoOwner, repoName, prNumber}]
+	if !found || time.Since(details.fetchedAt) > m.ttl {
+		return nil, false, nil
+	}
+
+	return details.data, true, nil
+}
+
+// StorePRDetails stores the full API response for a PR in the cache
+func (m *MemoryCache) StorePRDetails(repoOwner, repoName string, prNumber int, data []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.details[prKey{repoOwner, repoName, prNumber}] = memoryDetails{data: data, fetchedAt: time.Now()}
+	return nil
+}
+
+// GetValidationResult retrieves validation result from the cache
+// Returns status, cached (bool), and error
+func (m *MemoryCache) GetValidationResult(repoOwner, repoName string, prNumber int, changelogDesc, method string) (int, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	validation, found := m.validations[prKey{repoOwner, repoName, prNumber}]
+	if !found || validation.changelogDesc != changelogDesc || validation.method != method || time.Since(validation.lastValidated) > m.ttl {
+		return 0, false, nil
+	}
+
+	return validation.status, true, nil
+}
+
+// StoreValidationResult stores validation result in the cache
+func (m *MemoryCache) StoreValidationResult(repoOwner, repoName string, prNumber int, changelogDesc, method string, status int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.validations[prKey{repoOwner, repoName, prNumber}] = memoryValidation{
+		changelogDesc: changelogDesc,
+		method:        method,
+		status:        status,
+		lastValidated: time.Now(),
+	}
+	return nil
+}
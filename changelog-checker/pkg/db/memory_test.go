@@ -0,0 +1,56 @@
+package db
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMemoryCacheTTL(t *testing.T) {
+	if got := NewMemoryCache(0).ttl; got != DefaultTTL {
+		t.Errorf("NewMemoryCache(0) TTL = %s, want DefaultTTL", got)
+	}
+
+	m := NewMemoryCache(time.Hour)
+	record := PRRecord{Title: "title", State: "closed", Merged: true}
+	if err := m.StorePRInfo("o", "r", 1, record); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.StorePRDetails("o", "r", 1, []byte(`{}`)); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.StoreValidationResult("o", "r", 1, "desc", "substring", 0); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, found, _ := m.GetPRInfo("o", "r", 1); !found || got != record {
+		t.Errorf("GetPRInfo() = %+v, %v, want the stored record", got, found)
+	}
+	if _, found, _ := m.GetPRDetails("o", "r", 1); !found {
+		t.Error("GetPRDetails() not found before the TTL")
+	}
+	if _, found, _ := m.GetValidationResult("o", "r", 1, "desc", "substring"); !found {
+		t.Error("GetValidationResult() not found before the TTL")
+	}
+
+	// Age everything past the TTL
+	key := prKey{"o", "r", 1}
+	old := time.Now().Add(-2 * time.Hour)
+	m.prs[key] = memoryPR{record: record, fetchedAt: old}
+	m.details[key] = memoryDetails{data: []byte(`{}`), fetchedAt: old}
+	validation := m.validations[key]
+	validation.lastValidated = old
+	m.validations[key] = validation
+
+	if _, found, _ := m.GetPRInfo("o", "r", 1); found {
+		t.Error("GetPRInfo() found after the TTL")
+	}
+	if _, found, _ := m.GetPRDetails("o", "r", 1); found {
+		t.Error("GetPRDetails() found after the TTL")
+	}
+	if _, found, _ := m.GetValidationResult("o", "r", 1, "desc", "substring"); found {
+		t.Error("GetValidationResult() found after the TTL")
+	}
+	if got, found, _ := m.GetStalePRInfo("o", "r", 1); !found || got != record {
+		t.Errorf("GetStalePRInfo() = %+v, %v, want the expired record", got, found)
+	}
+}
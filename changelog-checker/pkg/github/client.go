@@ -1,137 +1,552 @@
 package github
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
 	"net/http"
+	"os"
 	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/gjermundgaraba/changelog-checker/pkg/db"
+	"github.com/gjermundgaraba/changelog-checker/pkg/httpclient"
 )
 
+// ErrNotCached is returned by the cache-only lookups when the PR has never been fetched
+var ErrNotCached = errors.New("PR info not cached")
+
+// ErrNotFound is returned when GitHub has no PR with the requested number
+var ErrNotFound = errors.New("PR not found")
+
+// notFoundError wraps ErrNotFound for a PR, so fresh and cached 404s return the same error
+func notFoundError(prNumber int) error {
+	return fmt.Errorf("PR #%d: %w", prNumber, ErrNotFound)
+}
+
+// RateLimitError is returned when the GitHub API rate limit has been hit
+type RateLimitError struct {
+	Reset time.Time
+}
+
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("rate limited until %s", e.Reset.Format(time.RFC3339))
+}
+
+// DefaultBaseURL is the base URL of the public GitHub API
+const DefaultBaseURL = "https://api.github.com"
+
 // Client is a GitHub API client with caching
 type Client struct {
 	httpClient   *http.Client
+	baseURL      string
 	token        string
-	db           *db.DB
-	rateLimited  bool
-	resetTime    time.Time
+	tokenSource  TokenSource
+	db           db.Cache
 	defaultOwner string
 	defaultRepo  string
+	logger       *slog.Logger
+
+	// WaitOnRateLimit makes requests block until the rate limit resets and retry once, instead of failing
+	WaitOnRateLimit bool
+
+	// rateLimitMu guards the rate limit state, which is shared by concurrent PR lookups
+	rateLimitMu sync.RWMutex
+	rateLimited bool
+	resetTime   time.Time
+}
+
+// ClientOption configures a Client
+type ClientOption func(*clientConfig)
+
+// clientConfig holds the settings ClientOptions can change
+type clientConfig struct {
+	baseURL        string
+	timeout        time.Duration
+	connectTimeout time.Duration
+}
+
+// WithBaseURL sets the API base URL, e.g. https://github.example.com/api/v3 for GitHub Enterprise
+// An empty base URL keeps DefaultBaseURL
+func WithBaseURL(baseURL string) ClientOption {
+	return func(c *clientConfig) {
+		if baseURL != "" {
+			c.baseURL = baseURL
+		}
+	}
+}
+
+// WithTimeout sets how long requests wait for GitHub to start responding or send more data, 0 or less keeps httpclient.DefaultTimeout
+// Raise it for large GraphQL batches or a slow GitHub Enterprise server
+func WithTimeout(timeout time.Duration) ClientOption {
+	return func(c *clientConfig) {
+		if timeout > 0 {
+			c.timeout = timeout
+		}
+	}
+}
+
+// WithConnectTimeout sets how long connecting to GitHub may take, 0 or less keeps httpclient.DefaultConnectTimeout
+func WithConnectTimeout(connectTimeout time.Duration) ClientOption {
+	return func(c *clientConfig) {
+		if connectTimeout > 0 {
+			c.connectTimeout = connectTimeout
+		}
+	}
 }
 
 // NewClient creates a new GitHub API client with caching
-func NewClient(token, defaultOwner, defaultRepo string, db *db.DB) *Client {
+// The cache can be the SQLite db.DB or an in-memory db.MemoryCache
+// A nil logger defaults to a text handler on stderr
+func NewClient(token, defaultOwner, defaultRepo string, cache db.Cache, logger *slog.Logger, opts ...ClientOption) *Client {
+	if logger == nil {
+		logger = slog.New(slog.NewTextHandler(os.Stderr, nil))
+	}
+
+	config := clientConfig{baseURL: DefaultBaseURL, timeout: httpclient.DefaultTimeout, connectTimeout: httpclient.DefaultConnectTimeout}
+	for _, opt := range opts {
+		opt(&config)
+	}
+
 	return &Client{
-		httpClient: &http.Client{
-			Timeout: 10 * time.Second,
-		},
+		httpClient:   httpclient.New(config.connectTimeout, config.timeout),
+		baseURL:      config.baseURL,
 		token:        token,
-		db:           db,
+		db:           cache,
 		defaultOwner: defaultOwner,
 		defaultRepo:  defaultRepo,
+		logger:       logger,
 	}
 }
 
+// SetBaseURL sets the API base URL, e.g. https://github.example.com/api/v3 for GitHub Enterprise
+func (c *Client) SetBaseURL(baseURL string) {
+	c.baseURL = baseURL
+}
+
+// SetTokenSource records where the token came from, so TestToken can report it
+func (c *Client) SetTokenSource(source TokenSource) {
+	c.tokenSource = source
+}
+
+// HasToken reports whether requests are authenticated
+func (c *Client) HasToken() bool {
+	return c.token != ""
+}
+
+// apiURL builds a request URL from the base URL and a path like /repos/owner/repo
+func (c *Client) apiURL(format string, args ...interface{}) string {
+	return strings.TrimRight(c.baseURL, "/") + fmt.Sprintf(format, args...)
+}
+
 // TestToken tests if the provided GitHub token is valid
 func (c *Client) TestToken() (bool, error) {
-	url := fmt.Sprintf("https://api.github.com/repos/%s/%s", c.defaultOwner, c.defaultRepo)
-	
+	url := c.apiURL("/repos/%s/%s", c.defaultOwner, c.defaultRepo)
+
 	req, err := http.NewRequest("GET", url, nil)
 	if err != nil {
 		return false, err
 	}
-	
+
 	if c.token != "" {
 		req.Header.Set("Authorization", "token "+c.token)
 	}
-	
+
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
 		return false, err
 	}
 	defer resp.Body.Close()
-	
-	return resp.StatusCode == http.StatusOK, nil
+
+	valid := resp.StatusCode == http.StatusOK
+	if c.token != "" {
+		if valid {
+			c.logger.Info("GitHub token is valid", "source", c.tokenSource)
+		} else {
+			c.logger.Warn("GitHub token was rejected", "source", c.tokenSource, "status", resp.StatusCode)
+		}
+	}
+
+	return valid, nil
 }
 
 // PRResponse represents the GitHub API response for a PR
 type PRResponse struct {
-	Title string `json:"title"`
-	Error struct {
-		Message string `json:"message"`
-	} `json:"error"`
+	Title  string `json:"title"`
+	State  string `json:"state"`
+	Merged bool   `json:"merged"`
+}
+
+// ErrorResponse represents the GitHub API error body, e.g. {"message": "Not Found", "documentation_url": "..."}
+type ErrorResponse struct {
+	Message          string `json:"message"`
+	DocumentationURL string `json:"documentation_url"`
+}
+
+// apiError builds an error for a non-successful response, including GitHub's error message if there is one
+func apiError(resp *http.Response) error {
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("GitHub API returned status %d", resp.StatusCode)
+	}
+
+	var errorResponse ErrorResponse
+	if err := json.Unmarshal(body, &errorResponse); err != nil || errorResponse.Message == "" {
+		return fmt.Errorf("GitHub API returned status %d", resp.StatusCode)
+	}
+
+	return fmt.Errorf("GitHub API returned status %d: %s", resp.StatusCode, errorResponse.Message)
 }
 
 // GetPRInfo gets PR info with caching
+// It only returns the title, use GetPRInfoFull for the state, merge status and labels. Both share the title cache
 func (c *Client) GetPRInfo(owner, repo string, prNumber int) (string, error) {
-	// If we're rate limited and the reset time hasn't passed, return error
-	if c.rateLimited && time.Now().Before(c.resetTime) {
-		return "", fmt.Errorf("rate limited until %s", c.resetTime.Format(time.RFC3339))
+	return c.GetPRInfoCtx(context.Background(), owner, repo, prNumber)
+}
+
+// GetPRInfoCtx gets PR info with caching, aborting the request if ctx is cancelled
+func (c *Client) GetPRInfoCtx(ctx context.Context, owner, repo string, prNumber int) (string, error) {
+	record, err := c.getPR(ctx, owner, repo, prNumber)
+	if err != nil {
+		return "", err
 	}
 
-	// Check cache first
-	title, found, err := c.db.GetPRInfo(owner, repo, prNumber)
+	return record.Title, nil
+}
+
+// GetPRMergeStatusCtx gets the state ("open" or "closed") of a PR and whether it was merged
+// Issues can't be merged, so a closed issue is reported as merged
+func (c *Client) GetPRMergeStatusCtx(ctx context.Context, owner, repo string, prNumber int) (string, bool, error) {
+	record, err := c.getPR(ctx, owner, repo, prNumber)
 	if err != nil {
-		log.Printf("Error checking cache: %v", err)
-	} else if found {
-		return title, nil
+		return "", false, err
 	}
 
-	// Not in cache or error, fetch from GitHub
-	url := fmt.Sprintf("https://api.github.com/repos/%s/%s/pulls/%d", owner, repo, prNumber)
-	
-	req, err := http.NewRequest("GET", url, nil)
+	return record.State, isMerged(record), nil
+}
+
+// isMerged checks if the PR was merged, or for issues, if the issue was closed
+func isMerged(record db.PRRecord) bool {
+	if record.Issue {
+		return record.State == "closed"
+	}
+	return record.Merged
+}
+
+// GetCachedPRInfo gets the PR title from the cache only, never making network calls
+// Expired entries are still returned, ErrNotCached is returned if the PR was never fetched
+func (c *Client) GetCachedPRInfo(owner, repo string, prNumber int) (string, error) {
+	record, err := c.getCachedPR(owner, repo, prNumber)
 	if err != nil {
 		return "", err
 	}
-	
+
+	return record.Title, nil
+}
+
+// GetCachedPRMergeStatus gets the state and merge status of a PR from the cache only
+func (c *Client) GetCachedPRMergeStatus(owner, repo string, prNumber int) (string, bool, error) {
+	record, err := c.getCachedPR(owner, repo, prNumber)
+	if err != nil {
+		return "", false, err
+	}
+
+	return record.State, isMerged(record), nil
+}
+
+// getCachedPR gets the PR from the cache regardless of its age
+func (c *Client) getCachedPR(owner, repo string, prNumber int) (db.PRRecord, error) {
+	record, found, err := c.db.GetStalePRInfo(owner, repo, prNumber)
+	if err != nil {
+		return db.PRRecord{}, err
+	}
+
+	if !found {
+		return db.PRRecord{}, fmt.Errorf("PR #%d: %w", prNumber, ErrNotCached)
+	}
+
+	if record.NotFound {
+		return db.PRRecord{}, notFoundError(prNumber)
+	}
+
+	return record, nil
+}
+
+// getPR gets the PR from the cache, or from GitHub if it is missing or expired
+func (c *Client) getPR(ctx context.Context, owner, repo string, prNumber int) (db.PRRecord, error) {
+	// Check cache first, entries cached before state was tracked need a refetch
+	record, found, err := c.db.GetPRInfo(owner, repo, prNumber)
+	if err != nil {
+		c.logger.Warn("Error checking cache", "pr", prNumber, "error", err)
+	} else if found && record.NotFound {
+		return db.PRRecord{}, notFoundError(prNumber)
+	} else if found && record.State != "" {
+		return record, nil
+	}
+
+	// If we're rate limited and the reset time hasn't passed, wait or return error
+	if resetTime, limited := c.rateLimitReset(); limited {
+		if !c.WaitOnRateLimit {
+			return db.PRRecord{}, &RateLimitError{Reset: resetTime}
+		}
+		if err := c.waitForRateLimitReset(ctx); err != nil {
+			return db.PRRecord{}, err
+		}
+	}
+
+	record, err = c.fetchPR(ctx, owner, repo, prNumber)
+
+	// Retry once after the rate limit resets
+	var rateLimitErr *RateLimitError
+	if errors.As(err, &rateLimitErr) && c.WaitOnRateLimit {
+		if err := c.waitForRateLimitReset(ctx); err != nil {
+			return db.PRRecord{}, err
+		}
+		record, err = c.fetchPR(ctx, owner, repo, prNumber)
+	}
+
+	return record, err
+}
+
+// rateLimitReset returns the rate limit reset time and whether we are still rate limited
+func (c *Client) rateLimitReset() (time.Time, bool) {
+	c.rateLimitMu.RLock()
+	defer c.rateLimitMu.RUnlock()
+
+	return c.resetTime, c.rateLimited && time.Now().Before(c.resetTime)
+}
+
+// setRateLimited records that requests are rate limited until resetTime and returns the matching error
+func (c *Client) setRateLimited(resetTime time.Time) error {
+	c.rateLimitMu.Lock()
+	defer c.rateLimitMu.Unlock()
+
+	c.resetTime = resetTime
+	c.rateLimited = true
+	return &RateLimitError{Reset: resetTime}
+}
+
+// clearRateLimit records that the rate limit has reset, unless another request has since moved the reset time
+func (c *Client) clearRateLimit(resetTime time.Time) {
+	c.rateLimitMu.Lock()
+	defer c.rateLimitMu.Unlock()
+
+	if c.resetTime.Equal(resetTime) {
+		c.rateLimited = false
+	}
+}
+
+// waitForRateLimitReset blocks until the rate limit reset time, or until ctx is done
+func (c *Client) waitForRateLimitReset(ctx context.Context) error {
+	resetTime, _ := c.rateLimitReset()
+	wait := time.Until(resetTime)
+	if wait <= 0 {
+		return nil
+	}
+
+	// No point in waiting if we'll hit the deadline before the reset
+	if deadline, ok := ctx.Deadline(); ok && deadline.Before(resetTime) {
+		return fmt.Errorf("rate limited until %s, which is past the context deadline", resetTime.Format(time.RFC3339))
+	}
+
+	c.logger.Warn("Rate limited by GitHub API, waiting for reset", "wait", wait.Round(time.Second), "reset", resetTime.Format(time.RFC3339))
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		c.clearRateLimit(resetTime)
+		return nil
+	}
+}
+
+// fetchPR fetches the PR from GitHub and caches it, revalidating an expired cache entry if there is one
+func (c *Client) fetchPR(ctx context.Context, owner, repo string, prNumber int) (db.PRRecord, error) {
+	// An expired cache entry can still be revalidated with its ETag
+	staleRecord, stale, err := c.db.GetStalePRInfo(owner, repo, prNumber)
+	if err != nil {
+		c.logger.Warn("Error checking cache", "pr", prNumber, "error", err)
+	}
+	stale = stale && staleRecord.ETag != "" && staleRecord.State != ""
+
+	// Numbers known to be issues are revalidated against the issues endpoint
+	if stale && staleRecord.Issue {
+		return c.fetchIssue(ctx, owner, repo, prNumber, staleRecord)
+	}
+
+	// Not in cache or error, fetch from GitHub
+	url := c.apiURL("/repos/%s/%s/pulls/%d", owner, repo, prNumber)
+
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return db.PRRecord{}, err
+	}
+
 	if c.token != "" {
 		req.Header.Set("Authorization", "token "+c.token)
 	}
-	
+
+	if stale {
+		req.Header.Set("If-None-Match", staleRecord.ETag)
+	}
+
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
-		return "", err
+		return db.PRRecord{}, err
 	}
 	defer resp.Body.Close()
-	
-	// Check for rate limiting
-	if resp.StatusCode == http.StatusForbidden || resp.StatusCode == http.StatusTooManyRequests {
-		// Parse the rate limit reset time
-		resetHeader := resp.Header.Get("X-RateLimit-Reset")
-		if resetHeader != "" {
-			resetTime, err := strconv.ParseInt(resetHeader, 10, 64)
-			if err == nil {
-				c.resetTime = time.Unix(resetTime, 0)
-				c.rateLimited = true
-				return "", fmt.Errorf("rate limited until %s", c.resetTime.Format(time.RFC3339))
-			}
+
+	// Nothing changed since we cached it, and 304s don't count against the rate limit
+	if resp.StatusCode == http.StatusNotModified && stale {
+		if err := c.db.TouchPRInfo(owner, repo, prNumber); err != nil {
+			c.logger.Warn("Error refreshing PR info cache", "pr", prNumber, "error", err)
 		}
-		return "", fmt.Errorf("rate limited by GitHub API")
+		return staleRecord, nil
+	}
+
+	// Check for rate limiting
+	if err := c.checkRateLimit(resp); err != nil {
+		return db.PRRecord{}, err
+	}
+
+	// Changelog entries sometimes reference the issue that was fixed rather than the PR
+	if resp.StatusCode == http.StatusNotFound {
+		return c.fetchIssue(ctx, owner, repo, prNumber, db.PRRecord{})
 	}
-	
+
 	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("GitHub API returned status %d", resp.StatusCode)
+		return db.PRRecord{}, apiError(resp)
 	}
-	
+
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return "", err
+		return db.PRRecord{}, err
 	}
-	
+
 	var prResponse PRResponse
 	if err := json.Unmarshal(body, &prResponse); err != nil {
-		return "", err
+		return db.PRRecord{}, err
+	}
+
+	record := db.PRRecord{
+		Title:  prResponse.Title,
+		State:  prResponse.State,
+		Merged: prResponse.Merged,
+		ETag:   resp.Header.Get("ETag"),
 	}
-	
+
 	// Cache the result
-	if err := c.db.StorePRInfo(owner, repo, prNumber, prResponse.Title); err != nil {
-		log.Printf("Error caching PR info: %v", err)
+	if err := c.db.StorePRInfo(owner, repo, prNumber, record); err != nil {
+		c.logger.Warn("Error caching PR info", "pr", prNumber, "error", err)
+	}
+
+	return record, nil
+}
+
+// checkRateLimit returns an error if the response was rejected because of the rate limit
+func (c *Client) checkRateLimit(resp *http.Response) error {
+	if resp.StatusCode != http.StatusForbidden && resp.StatusCode != http.StatusTooManyRequests {
+		return nil
+	}
+
+	// Parse the rate limit reset time
+	resetHeader := resp.Header.Get("X-RateLimit-Reset")
+	if resetHeader != "" {
+		resetTime, err := strconv.ParseInt(resetHeader, 10, 64)
+		if err == nil {
+			return c.setRateLimited(time.Unix(resetTime, 0))
+		}
+	}
+	return fmt.Errorf("rate limited by GitHub API: %w", apiError(resp))
+}
+
+// IssueResponse represents the GitHub API response for an issue
+// PullRequest is set when the issue is a PR, since GitHub serves PRs from the issues endpoint as well
+type IssueResponse struct {
+	Title       string           `json:"title"`
+	State       string           `json:"state"`
+	PullRequest *json.RawMessage `json:"pull_request"`
+}
+
+// fetchIssue fetches the number as an issue and caches it, revalidating staleRecord if it has an ETag
+func (c *Client) fetchIssue(ctx context.Context, owner, repo string, issueNumber int, staleRecord db.PRRecord) (db.PRRecord, error) {
+	url := c.apiURL("/repos/%s/%s/issues/%d", owner, repo, issueNumber)
+
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return db.PRRecord{}, err
 	}
-	
-	return prResponse.Title, nil
-}
\ No newline at end of file
+
+	if c.token != "" {
+		req.Header.Set("Authorization", "token "+c.token)
+	}
+
+	if staleRecord.ETag != "" {
+		req.Header.Set("If-None-Match", staleRecord.ETag)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return db.PRRecord{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified && staleRecord.ETag != "" {
+		if err := c.db.TouchPRInfo(owner, repo, issueNumber); err != nil {
+			c.logger.Warn("Error refreshing PR info cache", "pr", issueNumber, "error", err)
+		}
+		return staleRecord, nil
+	}
+
+	if err := c.checkRateLimit(resp); err != nil {
+		return db.PRRecord{}, err
+	}
+
+	// Remember numbers that don't exist, e.g. numbers from another repo, so we don't refetch them every run
+	if resp.StatusCode == http.StatusNotFound {
+		if err := c.db.StorePRInfo(owner, repo, issueNumber, db.PRRecord{NotFound: true}); err != nil {
+			c.logger.Warn("Error caching PR info", "pr", issueNumber, "error", err)
+		}
+		return db.PRRecord{}, notFoundError(issueNumber)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return db.PRRecord{}, apiError(resp)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return db.PRRecord{}, err
+	}
+
+	var issueResponse IssueResponse
+	if err := json.Unmarshal(body, &issueResponse); err != nil {
+		return db.PRRecord{}, err
+	}
+
+	// The pulls endpoint said there is no such PR, so don't trust the issues endpoint saying otherwise
+	if issueResponse.PullRequest != nil {
+		return db.PRRecord{}, notFoundError(issueNumber)
+	}
+
+	record := db.PRRecord{
+		Title: issueResponse.Title,
+		State: issueResponse.State,
+		ETag:  resp.Header.Get("ETag"),
+		Issue: true,
+	}
+
+	if err := c.db.StorePRInfo(owner, repo, issueNumber, record); err != nil {
+		c.logger.Warn("Error caching PR info", "pr", issueNumber, "error", err)
+	}
+
+	return record, nil
+}
@@ -0,0 +1,169 @@
+package github
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gjermundgaraba/changelog-checker/pkg/db"
+)
+
+// newTestClient creates a client for the server with an in-memory cache and no log output
+func newTestClient(serverURL string) *Client {
+	client := NewClient("test-token", "o", "r", db.NewMemoryCache(0), slog.New(slog.NewTextHandler(io.Discard, nil)))
+	client.SetBaseURL(serverURL)
+	return client
+}
+
+// newRateLimitedServer serves PRs, rejecting every third request with a 403 that resets rateLimitReset from now
+func newRateLimitedServer(t *testing.T, rateLimitReset time.Duration) *httptest.Server {
+	t.Helper()
+
+	pullRegex := regexp.MustCompile(`^/repos/o/r/pulls/(\d+)$`)
+	var requests atomic.Int64
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		match := pullRegex.FindStringSubmatch(r.URL.Path)
+		if match == nil {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+			http.NotFound(w, r)
+			return
+		}
+
+		if requests.Add(1)%3 == 0 {
+			w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(time.Now().Add(rateLimitReset).Unix(), 10))
+			http.Error(w, `{"message": "API rate limit exceeded"}`, http.StatusForbidden)
+			return
+		}
+
+		number, _ := strconv.Atoi(match[1])
+		fmt.Fprintf(w, `{"title": "PR %d", "state": "closed", "merged": true}`, number)
+	}))
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+// Run with -race, the rate limit state is shared by all the concurrent lookups
+func TestGetPRInfoConcurrentRateLimits(t *testing.T) {
+	const prs = 50
+
+	t.Run("fail fast", func(t *testing.T) {
+		server := newRateLimitedServer(t, time.Hour)
+		client := newTestClient(server.URL)
+
+		var wg sync.WaitGroup
+		var rateLimited atomic.Int64
+		for prNumber := 1; prNumber <= prs; prNumber++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+
+				title, err := client.GetPRInfo("o", "r", prNumber)
+				var rateLimitErr *RateLimitError
+				switch {
+				case errors.As(err, &rateLimitErr):
+					rateLimited.Add(1)
+				case err != nil:
+					t.Errorf("GetPRInfo(%d) error = %v", prNumber, err)
+				case title != fmt.Sprintf("PR %d", prNumber):
+					t.Errorf("GetPRInfo(%d) = %q", prNumber, title)
+				}
+			}()
+		}
+		wg.Wait()
+
+		if rateLimited.Load() == 0 {
+			t.Error("no lookup was rate limited")
+		}
+		if _, limited := client.rateLimitReset(); !limited {
+			t.Error("client is not rate limited after a 403")
+		}
+	})
+
+	t.Run("wait on rate limit", func(t *testing.T) {
+		// The reset header has second precision, so this waits for at most a couple of seconds
+		server := newRateLimitedServer(t, time.Second)
+		client := newTestClient(server.URL)
+		client.WaitOnRateLimit = true
+
+		var wg sync.WaitGroup
+		for prNumber := 1; prNumber <= prs; prNumber++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+
+				// A lookup that is rate limited again on its retry still fails, so keep going until it succeeds
+				for {
+					title, err := client.GetPRInfo("o", "r", prNumber)
+					var rateLimitErr *RateLimitError
+					if errors.As(err, &rateLimitErr) {
+						continue
+					}
+					if err != nil {
+						t.Errorf("GetPRInfo(%d) error = %v", prNumber, err)
+					} else if title != fmt.Sprintf("PR %d", prNumber) {
+						t.Errorf("GetPRInfo(%d) = %q", prNumber, title)
+					}
+					return
+				}
+			}()
+		}
+		wg.Wait()
+	})
+}
+
+func TestRequestURLs(t *testing.T) {
+	tests := []struct {
+		name        string
+		baseURL     string
+		wantAPIURL  string
+		wantGraphQL string
+	}{
+		{
+			name:        "default",
+			wantAPIURL:  "https://api.github.com/repos/o/r/pulls/12",
+			wantGraphQL: "https://api.github.com/graphql",
+		},
+		{
+			name:        "trailing slash",
+			baseURL:     "https://api.github.com/",
+			wantAPIURL:  "https://api.github.com/repos/o/r/pulls/12",
+			wantGraphQL: "https://api.github.com/graphql",
+		},
+		{
+			name:        "enterprise",
+			baseURL:     "https://github.example.com/api/v3",
+			wantAPIURL:  "https://github.example.com/api/v3/repos/o/r/pulls/12",
+			wantGraphQL: "https://github.example.com/api/graphql",
+		},
+		{
+			name:        "enterprise with trailing slash",
+			baseURL:     "https://github.example.com/api/v3/",
+			wantAPIURL:  "https://github.example.com/api/v3/repos/o/r/pulls/12",
+			wantGraphQL: "https://github.example.com/api/graphql",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := NewClient("", "o", "r", db.NewMemoryCache(0), nil, WithBaseURL(tt.baseURL))
+
+			if got := client.apiURL("/repos/%s/%s/pulls/%d", "o", "r", 12); got != tt.wantAPIURL {
+				t.Errorf("apiURL() = %q, want %q", got, tt.wantAPIURL)
+			}
+			if got := client.graphQLURL(); got != tt.wantGraphQL {
+				t.Errorf("graphQLURL() = %q, want %q", got, tt.wantGraphQL)
+			}
+		})
+	}
+}
@@ -0,0 +1,110 @@
+package github
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// ReportCommentMarker is a hidden marker added to report comments, so re-runs update the comment instead of adding one
+const ReportCommentMarker = "<!-- changelog-checker-report -->"
+
+// commentsPerPage is the page size used when looking for an existing report comment, the maximum GitHub allows
+const commentsPerPage = 100
+
+// issueComment holds the fields we need from the GitHub API response for an issue or PR comment
+type issueComment struct {
+	ID   int64  `json:"id"`
+	Body string `json:"body"`
+}
+
+// PostPRComment posts the body as a comment on the PR, or updates the comment from an earlier run if there is one
+// Earlier comments are found by ReportCommentMarker, which is appended to the body if it doesn't have it
+func (c *Client) PostPRComment(owner, repo string, prNumber int, body string) error {
+	return c.PostPRCommentCtx(context.Background(), owner, repo, prNumber, body)
+}
+
+// PostPRCommentCtx posts or updates the report comment on the PR, see PostPRComment
+func (c *Client) PostPRCommentCtx(ctx context.Context, owner, repo string, prNumber int, body string) error {
+	if c.token == "" {
+		return fmt.Errorf("a GitHub token is required to comment on PR #%d", prNumber)
+	}
+
+	if !strings.Contains(body, ReportCommentMarker) {
+		body = body + "\n" + ReportCommentMarker
+	}
+
+	existing, err := c.findReportComment(ctx, owner, repo, prNumber)
+	if err != nil {
+		return err
+	}
+
+	// PRs are issues as far as comments go, so both use the issue comment endpoints
+	payload := map[string]string{"body": body}
+	if existing != 0 {
+		return c.sendJSON(ctx, "PATCH", c.apiURL("/repos/%s/%s/issues/comments/%d", owner, repo, existing), payload)
+	}
+	return c.sendJSON(ctx, "POST", c.apiURL("/repos/%s/%s/issues/%d/comments", owner, repo, prNumber), payload)
+}
+
+// findReportComment returns the ID of the first comment on the PR with ReportCommentMarker, or 0 if there is none
+func (c *Client) findReportComment(ctx context.Context, owner, repo string, prNumber int) (int64, error) {
+	for page := 1; ; page++ {
+		data, status, err := c.getRaw(ctx, c.apiURL("/repos/%s/%s/issues/%d/comments?per_page=%d&page=%d", owner, repo, prNumber, commentsPerPage, page))
+		if err != nil {
+			return 0, err
+		}
+		if status == http.StatusNotFound {
+			return 0, notFoundError(prNumber)
+		}
+
+		var comments []issueComment
+		if err := json.Unmarshal(data, &comments); err != nil {
+			return 0, err
+		}
+
+		for _, comment := range comments {
+			if strings.Contains(comment.Body, ReportCommentMarker) {
+				return comment.ID, nil
+			}
+		}
+
+		if len(comments) < commentsPerPage {
+			return 0, nil
+		}
+	}
+}
+
+// sendJSON sends the payload as JSON with the given method, failing on any non-2xx response
+func (c *Client) sendJSON(ctx context.Context, method, url string, payload interface{}) error {
+	jsonData, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewReader(jsonData))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "token "+c.token)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if err := c.checkRateLimit(resp); err != nil {
+		return err
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return apiError(resp)
+	}
+
+	return nil
+}
@@ -0,0 +1,135 @@
+package github
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// compareResponse holds the fields we need from the GitHub compare API
+type compareResponse struct {
+	// Status is "identical", "behind", "ahead" or "diverged", relative to the base
+	Status string `json:"status"`
+}
+
+// VerifyPRsInRef checks which PRs of the default repository landed in ref, e.g. the v1.2.0 tag
+func (c *Client) VerifyPRsInRef(prNumbers []int, ref string) (map[int]bool, error) {
+	return c.VerifyPRsInRefCtx(context.Background(), c.defaultOwner, c.defaultRepo, prNumbers, ref)
+}
+
+// VerifyPRsInRefCtx checks which PRs landed in ref, either merged into it or backported to it
+// A PR is in the ref if its merge commit is the ref or one of its ancestors, or if a commit in the ref since the PR was
+// merged mentions it, which is how backports and cherry-picks reach release branches: their message references the
+// original PR as "(#N)" or "backport #N", or says which merge commit was cherry-picked. PRs that were never merged are
+// not in any ref.
+func (c *Client) VerifyPRsInRefCtx(ctx context.Context, owner, repo string, prNumbers []int, ref string) (map[int]bool, error) {
+	inRef := make(map[int]bool, len(prNumbers))
+	for _, prNumber := range prNumbers {
+		info, err := c.GetPRInfoFullCtx(ctx, owner, repo, prNumber)
+		if err != nil {
+			return nil, err
+		}
+
+		if info.MergeCommitSHA == "" {
+			inRef[prNumber] = false
+			continue
+		}
+
+		contained, err := c.refContainsCommit(ctx, owner, repo, ref, info.MergeCommitSHA)
+		if err != nil {
+			return nil, fmt.Errorf("PR #%d: %w", prNumber, err)
+		}
+		if !contained {
+			contained, err = c.refMentionsPR(ctx, owner, repo, ref, prNumber, info)
+			if err != nil {
+				return nil, fmt.Errorf("PR #%d: %w", prNumber, err)
+			}
+		}
+		inRef[prNumber] = contained
+	}
+
+	return inRef, nil
+}
+
+// maxRefCommitPages limits how many pages of ref commits are searched for a backport of a single PR
+const maxRefCommitPages = 10
+
+// commitResponse holds the fields we need from the GitHub commits API
+type commitResponse struct {
+	Commit struct {
+		Message string `json:"message"`
+	} `json:"commit"`
+}
+
+// refMentionsPR reports whether a commit in ref made since the PR was merged backports or cherry-picks it
+// Backports can only happen after the merge, so only commits since then are listed, newest first
+func (c *Client) refMentionsPR(ctx context.Context, owner, repo, ref string, prNumber int, info PRInfo) (bool, error) {
+	query := url.Values{"sha": {ref}, "per_page": {"100"}}
+	if !info.MergedAt.IsZero() {
+		query.Set("since", info.MergedAt.UTC().Format(time.RFC3339))
+	}
+
+	prReference := regexp.MustCompile(fmt.Sprintf(`#%d\b`, prNumber))
+	for page := 1; page <= maxRefCommitPages; page++ {
+		query.Set("page", fmt.Sprint(page))
+		data, status, err := c.getRaw(ctx, c.apiURL("/repos/%s/%s/commits?%s", owner, repo, query.Encode()))
+		if err != nil {
+			return false, err
+		}
+		if data == nil {
+			return false, fmt.Errorf("can't list the commits of %s, got status %d", ref, status)
+		}
+
+		var commits []commitResponse
+		if err := json.Unmarshal(data, &commits); err != nil {
+			return false, err
+		}
+
+		for _, commit := range commits {
+			if mentionsPR(commit.Commit.Message, prReference, info.MergeCommitSHA) {
+				return true, nil
+			}
+		}
+
+		if len(commits) < 100 {
+			return false, nil
+		}
+	}
+
+	c.logger.Warn("Stopped searching for a backport", "pr", prNumber, "ref", ref, "commits", maxRefCommitPages*100)
+	return false, nil
+}
+
+// mentionsPR reports whether a commit message references the PR number, e.g. "feat: foo (#123)" or
+// "feat: foo (backport #123) (#456)", or is a cherry-pick of its merge commit
+// prReference matches "#N" for the PR number
+func mentionsPR(message string, prReference *regexp.Regexp, mergeCommitSHA string) bool {
+	if prReference.MatchString(message) {
+		return true
+	}
+
+	return mergeCommitSHA != "" && strings.Contains(message, "cherry picked from commit "+mergeCommitSHA)
+}
+
+// refContainsCommit reports whether the commit is reachable from ref, using the compare API with ref as the base
+func (c *Client) refContainsCommit(ctx context.Context, owner, repo, ref, sha string) (bool, error) {
+	data, status, err := c.getRaw(ctx, c.apiURL("/repos/%s/%s/compare/%s...%s", owner, repo, url.PathEscape(ref), sha))
+	if err != nil {
+		return false, err
+	}
+	if data == nil {
+		return false, fmt.Errorf("can't compare %s with %s, got status %d", ref, sha, status)
+	}
+
+	var response compareResponse
+	if err := json.Unmarshal(data, &response); err != nil {
+		return false, err
+	}
+
+	// The commit is behind (or is) the ref exactly when the ref already contains it
+	return response.Status == "behind" || response.Status == "identical", nil
+}
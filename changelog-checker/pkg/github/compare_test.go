@@ -0,0 +1,132 @@
+package github
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakePR is a PR served by newFakeRefServer
+type fakePR struct {
+	mergeCommitSHA string
+	mergedAt       time.Time
+}
+
+// newFakeRefServer serves the PRs, a compare API that says which merge commits are ancestors of the ref,
+// and the ref's commit messages, newest first
+func newFakeRefServer(t *testing.T, prs map[int]fakePR, ancestors map[string]bool, refMessages []string) *httptest.Server {
+	t.Helper()
+
+	pullRegex := regexp.MustCompile(`^/repos/o/r/pulls/(\d+)$`)
+	compareRegex := regexp.MustCompile(`^/repos/o/r/compare/v1\.2\.0\.\.\.([0-9a-f]+)$`)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case pullRegex.MatchString(r.URL.Path):
+			number, _ := strconv.Atoi(pullRegex.FindStringSubmatch(r.URL.Path)[1])
+			pr, ok := prs[number]
+			if !ok {
+				http.NotFound(w, r)
+				return
+			}
+			response := map[string]interface{}{"title": fmt.Sprintf("PR %d", number), "state": "closed", "head": map[string]string{}}
+			if pr.mergeCommitSHA != "" {
+				response["merged"] = true
+				response["merged_at"] = pr.mergedAt
+				response["merge_commit_sha"] = pr.mergeCommitSHA
+			}
+			_ = json.NewEncoder(w).Encode(response)
+		case compareRegex.MatchString(r.URL.Path):
+			status := "ahead"
+			if ancestors[compareRegex.FindStringSubmatch(r.URL.Path)[1]] {
+				status = "behind"
+			}
+			fmt.Fprintf(w, `{"status": %q}`, status)
+		case r.URL.Path == "/repos/o/r/commits":
+			if sha := r.URL.Query().Get("sha"); sha != "v1.2.0" {
+				t.Errorf("commits listed for %q, want v1.2.0", sha)
+			}
+			if r.URL.Query().Get("page") != "1" {
+				fmt.Fprint(w, `[]`)
+				return
+			}
+			commits := make([]map[string]map[string]string, 0, len(refMessages))
+			for _, message := range refMessages {
+				commits = append(commits, map[string]map[string]string{"commit": {"message": message}})
+			}
+			_ = json.NewEncoder(w).Encode(commits)
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL)
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func TestVerifyPRsInRef(t *testing.T) {
+	mergedAt := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	prs := map[int]fakePR{
+		// Merged straight into the ref
+		10: {mergeCommitSHA: "aaa111", mergedAt: mergedAt},
+		// Backported with a mergify style title
+		20: {mergeCommitSHA: "bbb222", mergedAt: mergedAt},
+		// Cherry-picked by hand
+		30: {mergeCommitSHA: "ccc333", mergedAt: mergedAt},
+		// Merged to main, never backported, but #300 mentions a longer number starting with 30
+		40: {mergeCommitSHA: "ddd444", mergedAt: mergedAt},
+		// Never merged
+		50: {},
+	}
+	refMessages := []string{
+		"chore: release v1.2.0 (#300)",
+		"fix: handle timeouts (backport #20) (#120)",
+		"feat: add thing\n\n(cherry picked from commit ccc333)",
+		"docs: mention #400 in the readme (#121)",
+	}
+
+	server := newFakeRefServer(t, prs, map[string]bool{"aaa111": true}, refMessages)
+	client := newTestClient(server.URL)
+
+	inRef, err := client.VerifyPRsInRef([]int{10, 20, 30, 40, 50}, "v1.2.0")
+	if err != nil {
+		t.Fatalf("VerifyPRsInRef() error = %v", err)
+	}
+
+	want := map[int]bool{10: true, 20: true, 30: true, 40: false, 50: false}
+	for prNumber, wantIn := range want {
+		if inRef[prNumber] != wantIn {
+			t.Errorf("PR #%d in ref = %v, want %v", prNumber, inRef[prNumber], wantIn)
+		}
+	}
+}
+
+func TestMentionsPR(t *testing.T) {
+	prReference := regexp.MustCompile(`#12\b`)
+	tests := []struct {
+		message string
+		want    bool
+	}{
+		{message: "feat: add thing (#12)", want: true},
+		{message: "feat: add thing (backport #12) (#99)", want: true},
+		{message: "feat: add thing (#123)", want: false},
+		{message: "feat: add thing (#112)", want: false},
+		{message: "feat: add thing\n\n(cherry picked from commit abc123)", want: true},
+		{message: "feat: add thing\n\n(cherry picked from commit def456)", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(strings.SplitN(tt.message, "\n", 2)[0], func(t *testing.T) {
+			if got := mentionsPR(tt.message, prReference, "abc123"); got != tt.want {
+				t.Errorf("mentionsPR(%q) = %v, want %v", tt.message, got, tt.want)
+			}
+		})
+	}
+}
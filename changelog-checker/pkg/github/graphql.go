@@ -0,0 +1,205 @@
+package github
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/gjermundgaraba/changelog-checker/pkg/db"
+)
+
+// graphQLBatchSize is the number of PRs fetched per GraphQL request, to stay well within the node limits
+const graphQLBatchSize = 50
+
+// GraphQLRequest represents a request to the GitHub GraphQL API
+type GraphQLRequest struct {
+	Query     string                 `json:"query"`
+	Variables map[string]interface{} `json:"variables"`
+}
+
+// GraphQLPR represents a PR node in the GitHub GraphQL API response
+type GraphQLPR struct {
+	Typename string `json:"__typename"` // PullRequest or Issue
+	Title    string `json:"title"`
+	State    string `json:"state"` // OPEN, CLOSED or MERGED
+	Merged   bool   `json:"merged"`
+}
+
+// GraphQLPRBatchResponse represents the GitHub GraphQL API response for a batch of aliased PRs
+type GraphQLPRBatchResponse struct {
+	Data struct {
+		Repository map[string]*GraphQLPR `json:"repository"`
+	} `json:"data"`
+	Errors []struct {
+		Type    string   `json:"type"`
+		Path    []string `json:"path"`
+		Message string   `json:"message"`
+	} `json:"errors"`
+}
+
+// GetPRInfoBatch gets the titles of many PRs with as few requests as possible
+func (c *Client) GetPRInfoBatch(owner, repo string, prNumbers []int) (map[int]string, error) {
+	return c.GetPRInfoBatchCtx(context.Background(), owner, repo, prNumbers)
+}
+
+// GetPRInfoBatchCtx gets the titles of many PRs, using the cache where possible and the GraphQL API
+// for the rest in chunks of 50. PRs that don't exist are left out of the returned map.
+func (c *Client) GetPRInfoBatchCtx(ctx context.Context, owner, repo string, prNumbers []int) (map[int]string, error) {
+	titles := make(map[int]string)
+
+	var uncached []int
+	for _, prNumber := range prNumbers {
+		record, found, err := c.db.GetPRInfo(owner, repo, prNumber)
+		if err != nil {
+			c.logger.Warn("Error checking cache", "pr", prNumber, "error", err)
+		}
+
+		switch {
+		case found && record.NotFound:
+		case found && record.State != "":
+			titles[prNumber] = record.Title
+		default:
+			uncached = append(uncached, prNumber)
+		}
+	}
+
+	if len(uncached) == 0 {
+		return titles, nil
+	}
+
+	// The GraphQL API can't be used anonymously, fall back to one REST request per PR
+	if c.token == "" {
+		for _, prNumber := range uncached {
+			record, err := c.getPR(ctx, owner, repo, prNumber)
+			if errors.Is(err, ErrNotFound) {
+				continue
+			} else if err != nil {
+				return titles, err
+			}
+			titles[prNumber] = record.Title
+		}
+		return titles, nil
+	}
+
+	for start := 0; start < len(uncached); start += graphQLBatchSize {
+		end := min(start+graphQLBatchSize, len(uncached))
+
+		records, err := c.fetchPRBatch(ctx, owner, repo, uncached[start:end])
+		if err != nil {
+			return titles, err
+		}
+
+		for prNumber, record := range records {
+			if err := c.db.StorePRInfo(owner, repo, prNumber, record); err != nil {
+				c.logger.Warn("Error caching PR info", "pr", prNumber, "error", err)
+			}
+
+			if !record.NotFound {
+				titles[prNumber] = record.Title
+			}
+		}
+	}
+
+	return titles, nil
+}
+
+// graphQLURL returns the GraphQL endpoint for the base URL
+// GitHub Enterprise serves REST under /api/v3 and GraphQL under /api/graphql
+func (c *Client) graphQLURL() string {
+	baseURL := strings.TrimRight(c.baseURL, "/")
+	if strings.HasSuffix(baseURL, "/api/v3") {
+		return strings.TrimSuffix(baseURL, "/v3") + "/graphql"
+	}
+	return baseURL + "/graphql"
+}
+
+// fetchPRBatch fetches a single batch of PRs with one GraphQL query using an alias per PR
+func (c *Client) fetchPRBatch(ctx context.Context, owner, repo string, prNumbers []int) (map[int]db.PRRecord, error) {
+	var query strings.Builder
+	query.WriteString("query($owner: String!, $name: String!) { repository(owner: $owner, name: $name) {")
+	for _, prNumber := range prNumbers {
+		// Changelog entries sometimes reference the issue that was fixed rather than the PR
+		query.WriteString(fmt.Sprintf(" pr%d: issueOrPullRequest(number: %d) { __typename ... on PullRequest { title state merged } ... on Issue { title state } }", prNumber, prNumber))
+	}
+	query.WriteString(" } }")
+
+	jsonData, err := json.Marshal(GraphQLRequest{
+		Query: query.String(),
+		Variables: map[string]interface{}{
+			"owner": owner,
+			"name":  repo,
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", c.graphQLURL(), bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "bearer "+c.token)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	// Check for rate limiting
+	if err := c.checkRateLimit(resp); err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, apiError(resp)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var batchResponse GraphQLPRBatchResponse
+	if err := json.Unmarshal(body, &batchResponse); err != nil {
+		return nil, err
+	}
+
+	// Errors for missing PRs come with a null node, anything else fails the batch
+	for _, graphQLErr := range batchResponse.Errors {
+		if graphQLErr.Type != "NOT_FOUND" {
+			return nil, fmt.Errorf("GitHub GraphQL API error: %s", graphQLErr.Message)
+		}
+	}
+
+	records := make(map[int]db.PRRecord)
+	for _, prNumber := range prNumbers {
+		pr := batchResponse.Data.Repository[fmt.Sprintf("pr%d", prNumber)]
+		if pr == nil {
+			records[prNumber] = db.PRRecord{NotFound: true}
+			continue
+		}
+
+		// Match the REST API's state, where merged PRs are "closed"
+		state := "open"
+		if pr.State != "OPEN" {
+			state = "closed"
+		}
+
+		records[prNumber] = db.PRRecord{
+			Title:  pr.Title,
+			State:  state,
+			Merged: pr.Merged,
+			Issue:  pr.Typename == "Issue",
+		}
+	}
+
+	return records, nil
+}
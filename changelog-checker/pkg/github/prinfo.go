@@ -0,0 +1,177 @@
+package github
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gjermundgaraba/changelog-checker/pkg/db"
+)
+
+// PRInfo is the PR information used by checks that need more than the title
+type PRInfo struct {
+	Title    string
+	State    string // "open" or "closed"
+	Merged   bool
+	Labels   []string
+	MergedAt time.Time // Zero if the PR wasn't merged
+	// MergeCommitSHA is the commit the PR was merged as, empty if the PR wasn't merged
+	MergeCommitSHA string
+
+	// Issue is set when the number refers to an issue rather than a PR
+	Issue bool
+}
+
+// prDetailsResponse holds the fields of PRInfo in the GitHub API response for a PR or an issue
+type prDetailsResponse struct {
+	Title    string     `json:"title"`
+	State    string     `json:"state"`
+	Merged   bool       `json:"merged"`
+	MergedAt *time.Time `json:"merged_at"`
+	// GitHub also sets merge_commit_sha on open PRs for the test merge, so it is only used if the PR was merged
+	MergeCommitSHA string `json:"merge_commit_sha"`
+	Labels         []struct {
+		Name string `json:"name"`
+	} `json:"labels"`
+	// Head is only in responses from the pulls endpoint, so it tells PRs and issues apart
+	Head *json.RawMessage `json:"head"`
+}
+
+// GetPRInfoFull gets the title, state, merge status and labels of a PR with caching
+func (c *Client) GetPRInfoFull(owner, repo string, prNumber int) (PRInfo, error) {
+	return c.GetPRInfoFullCtx(context.Background(), owner, repo, prNumber)
+}
+
+// GetPRInfoFullCtx gets the title, state, merge status and labels of a PR with caching
+// The full API response is cached, and the title and state are cached for GetPRInfo as well
+func (c *Client) GetPRInfoFullCtx(ctx context.Context, owner, repo string, prNumber int) (PRInfo, error) {
+	data, found, err := c.db.GetPRDetails(owner, repo, prNumber)
+	if err != nil {
+		c.logger.Warn("Error checking PR details cache", "pr", prNumber, "error", err)
+	} else if found {
+		return parsePRDetails(data)
+	}
+
+	// Numbers that are known not to exist don't need another request
+	if record, found, err := c.db.GetPRInfo(owner, repo, prNumber); err == nil && found && record.NotFound {
+		return PRInfo{}, notFoundError(prNumber)
+	}
+
+	if resetTime, limited := c.rateLimitReset(); limited {
+		if !c.WaitOnRateLimit {
+			return PRInfo{}, &RateLimitError{Reset: resetTime}
+		}
+		if err := c.waitForRateLimitReset(ctx); err != nil {
+			return PRInfo{}, err
+		}
+	}
+
+	data, err = c.fetchPRDetails(ctx, owner, repo, prNumber)
+	if err != nil {
+		return PRInfo{}, err
+	}
+
+	info, err := parsePRDetails(data)
+	if err != nil {
+		return PRInfo{}, err
+	}
+
+	if err := c.db.StorePRDetails(owner, repo, prNumber, data); err != nil {
+		c.logger.Warn("Error caching PR details", "pr", prNumber, "error", err)
+	}
+	record := db.PRRecord{Title: info.Title, State: info.State, Merged: info.Merged, Issue: info.Issue}
+	if err := c.db.StorePRInfo(owner, repo, prNumber, record); err != nil {
+		c.logger.Warn("Error caching PR info", "pr", prNumber, "error", err)
+	}
+
+	return info, nil
+}
+
+// fetchPRDetails fetches the full API response for a PR, falling back to the issue if there is no such PR
+func (c *Client) fetchPRDetails(ctx context.Context, owner, repo string, prNumber int) ([]byte, error) {
+	data, status, err := c.getRaw(ctx, c.apiURL("/repos/%s/%s/pulls/%d", owner, repo, prNumber))
+	if err != nil {
+		return nil, err
+	}
+
+	if status == http.StatusNotFound {
+		data, status, err = c.getRaw(ctx, c.apiURL("/repos/%s/%s/issues/%d", owner, repo, prNumber))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if status == http.StatusNotFound {
+		if err := c.db.StorePRInfo(owner, repo, prNumber, db.PRRecord{NotFound: true}); err != nil {
+			c.logger.Warn("Error caching PR info", "pr", prNumber, "error", err)
+		}
+		return nil, notFoundError(prNumber)
+	}
+
+	return data, nil
+}
+
+// getRaw gets the url and returns the response body, a 404 is returned as a status rather than an error
+func (c *Client) getRaw(ctx context.Context, url string) ([]byte, int, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if c.token != "" {
+		req.Header.Set("Authorization", "token "+c.token)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+
+	if err := c.checkRateLimit(resp); err != nil {
+		return nil, 0, err
+	}
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, resp.StatusCode, nil
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, 0, apiError(resp)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return body, resp.StatusCode, nil
+}
+
+// parsePRDetails parses a cached PR or issue API response
+func parsePRDetails(data []byte) (PRInfo, error) {
+	var response prDetailsResponse
+	if err := json.Unmarshal(data, &response); err != nil {
+		return PRInfo{}, err
+	}
+
+	info := PRInfo{
+		Title:  response.Title,
+		State:  response.State,
+		Merged: response.Merged,
+		Issue:  response.Head == nil,
+	}
+	if response.MergedAt != nil {
+		info.MergedAt = *response.MergedAt
+	}
+	if response.Merged {
+		info.MergeCommitSHA = response.MergeCommitSHA
+	}
+	for _, label := range response.Labels {
+		info.Labels = append(info.Labels, label.Name)
+	}
+
+	return info, nil
+}
@@ -0,0 +1,53 @@
+package github
+
+import (
+	"fmt"
+	"net/url"
+	"os/exec"
+	"strings"
+)
+
+// DetectRepo reads the owner and name of the GitHub repository cloned in dir from its origin remote
+// Both SSH and HTTPS remotes are supported, for github.com as well as GitHub Enterprise hosts
+func DetectRepo(dir string) (owner, name string, err error) {
+	cmd := exec.Command("git", "config", "--get", "remote.origin.url")
+	cmd.Dir = dir
+	out, err := cmd.Output()
+	if err != nil {
+		return "", "", fmt.Errorf("failed to read the origin remote in %s: %w", dir, err)
+	}
+
+	return ParseRepoURL(strings.TrimSpace(string(out)))
+}
+
+// ParseRepoURL parses the owner and name from a git remote URL, e.g.
+//
+//	git@github.com:cosmos/ibc-go.git
+//	ssh://git@github.example.com:2222/cosmos/ibc-go.git
+//	https://github.com/cosmos/ibc-go
+//
+// The host is not checked, so GitHub Enterprise remotes parse the same way as github.com
+func ParseRepoURL(remote string) (owner, name string, err error) {
+	var path string
+	if strings.Contains(remote, "://") {
+		u, err := url.Parse(remote)
+		if err != nil {
+			return "", "", fmt.Errorf("invalid remote URL %s: %w", remote, err)
+		}
+		path = u.Path
+	} else if _, after, found := strings.Cut(remote, ":"); found {
+		// scp-like syntax used by SSH remotes, user@host:owner/name.git
+		path = after
+	} else {
+		return "", "", fmt.Errorf("unsupported remote URL %s", remote)
+	}
+
+	path = strings.TrimSuffix(strings.Trim(path, "/"), ".git")
+	parts := strings.Split(path, "/")
+	if len(parts) < 2 || parts[len(parts)-2] == "" || parts[len(parts)-1] == "" {
+		return "", "", fmt.Errorf("remote URL %s has no owner/name path", remote)
+	}
+
+	// GitHub Enterprise can be served under a path prefix, the repository is always the last two segments
+	return parts[len(parts)-2], parts[len(parts)-1], nil
+}
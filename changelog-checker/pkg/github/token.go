@@ -0,0 +1,96 @@
+package github
+
+import (
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// TokenSource describes where a GitHub token was found
+type TokenSource string
+
+const (
+	TokenSourceNone     TokenSource = "none"
+	TokenSourceArgument TokenSource = "argument"
+	TokenSourceEnv      TokenSource = "GITHUB_TOKEN"
+	TokenSourceGHCLI    TokenSource = "gh CLI"
+)
+
+// ResolveToken finds a GitHub token, checking in order the explicit token, the GITHUB_TOKEN environment variable
+// and the hosts.yml written by `gh auth login`, so users already authenticated with gh don't need to export a token
+// The gh CLI token is the one for the host of baseURL, e.g. a GitHub Enterprise server, an empty baseURL means github.com
+// Returns an empty token and TokenSourceNone if none of them have a token
+func ResolveToken(explicit, baseURL string) (string, TokenSource) {
+	if token := strings.TrimSpace(explicit); token != "" {
+		return token, TokenSourceArgument
+	}
+
+	if token := strings.TrimSpace(os.Getenv("GITHUB_TOKEN")); token != "" {
+		return token, TokenSourceEnv
+	}
+
+	if token := ghCLIToken(ghHostFor(baseURL)); token != "" {
+		return token, TokenSourceGHCLI
+	}
+
+	return "", TokenSourceNone
+}
+
+// ghHost is a host entry in the gh CLI hosts.yml
+type ghHost struct {
+	OAuthToken string `yaml:"oauth_token"`
+}
+
+// ghHostFor returns the gh CLI host name of an API base URL, e.g. github.com for https://api.github.com and
+// github.example.com for https://github.example.com/api/v3
+func ghHostFor(baseURL string) string {
+	if baseURL == "" {
+		baseURL = DefaultBaseURL
+	}
+
+	u, err := url.Parse(baseURL)
+	if err != nil || u.Hostname() == "" {
+		return "github.com"
+	}
+
+	// gh logs in to the web host, which is the API host without "api.", e.g. github.com and tenant.ghe.com
+	return strings.TrimPrefix(strings.ToLower(u.Hostname()), "api.")
+}
+
+// ghCLIToken reads the token for host from the gh CLI hosts.yml
+// Returns an empty string if the file doesn't exist or has no token, e.g. because gh stores it in the system keyring
+func ghCLIToken(host string) string {
+	bz, err := os.ReadFile(filepath.Join(ghConfigDir(), "hosts.yml"))
+	if err != nil {
+		return ""
+	}
+
+	var hosts map[string]ghHost
+	if err := yaml.Unmarshal(bz, &hosts); err != nil {
+		return ""
+	}
+
+	return strings.TrimSpace(hosts[host].OAuthToken)
+}
+
+// ghConfigDir returns the gh CLI config directory, following the same lookup order as gh itself
+func ghConfigDir() string {
+	if dir := os.Getenv("GH_CONFIG_DIR"); dir != "" {
+		return dir
+	}
+
+	if dir := os.Getenv("XDG_CONFIG_HOME"); dir != "" {
+		return filepath.Join(dir, "gh")
+	}
+
+	if dir := os.Getenv("AppData"); runtime.GOOS == "windows" && dir != "" {
+		return filepath.Join(dir, "GitHub CLI")
+	}
+
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, ".config", "gh")
+}
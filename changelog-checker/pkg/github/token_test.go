@@ -0,0 +1,60 @@
+package github
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestGHHostFor(t *testing.T) {
+	tests := []struct {
+		baseURL string
+		want    string
+	}{
+		{"", "github.com"},
+		{"https://api.github.com", "github.com"},
+		{"https://api.github.com/", "github.com"},
+		{"https://github.example.com/api/v3", "github.example.com"},
+		{"https://GitHub.Example.com:8443/api/v3", "github.example.com"},
+		{"https://api.tenant.ghe.com", "tenant.ghe.com"},
+		{"not a url", "github.com"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.baseURL, func(t *testing.T) {
+			if got := ghHostFor(tt.baseURL); got != tt.want {
+				t.Errorf("ghHostFor(%q) = %q, want %q", tt.baseURL, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolveTokenUsesGHHostOfBaseURL(t *testing.T) {
+	configDir := t.TempDir()
+	hosts := "github.com:\n    oauth_token: public-token\ngithub.example.com:\n    oauth_token: enterprise-token\n"
+	if err := os.WriteFile(filepath.Join(configDir, "hosts.yml"), []byte(hosts), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("GH_CONFIG_DIR", configDir)
+	t.Setenv("GITHUB_TOKEN", "")
+
+	tests := []struct {
+		baseURL    string
+		want       string
+		wantSource TokenSource
+	}{
+		{"", "public-token", TokenSourceGHCLI},
+		{DefaultBaseURL, "public-token", TokenSourceGHCLI},
+		{"https://github.example.com/api/v3", "enterprise-token", TokenSourceGHCLI},
+		{"https://github.other.com/api/v3", "", TokenSourceNone},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.baseURL, func(t *testing.T) {
+			token, source := ResolveToken("", tt.baseURL)
+			if token != tt.want || source != tt.wantSource {
+				t.Errorf("ResolveToken() = %q, %q, want %q, %q", token, source, tt.want, tt.wantSource)
+			}
+		})
+	}
+}
@@ -0,0 +1,92 @@
+// Package httpclient builds the http.Client shared by the GitHub and OpenAI clients
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"time"
+)
+
+const (
+	// DefaultTimeout is how long a request waits for the server to start responding, and for more of the body to arrive
+	DefaultTimeout = 10 * time.Second
+	// DefaultConnectTimeout is how long connecting, including the TLS handshake, may take
+	DefaultConnectTimeout = 10 * time.Second
+)
+
+// ErrIdleTimeout is returned when reading a response body that stopped arriving for longer than the timeout
+var ErrIdleTimeout = errors.New("response body stalled")
+
+// New creates an http.Client with separate connect and response timeouts
+// Unlike http.Client.Timeout, timeout doesn't bound the whole request. It covers waiting for the response headers
+// and every wait for more of the body, so a large response that keeps streaming in isn't cut off, while one that
+// stalls halfway fails instead of hanging forever.
+func New(connectTimeout, timeout time.Duration) *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = (&net.Dialer{
+		Timeout:   connectTimeout,
+		KeepAlive: 30 * time.Second,
+	}).DialContext
+	transport.TLSHandshakeTimeout = connectTimeout
+	transport.ResponseHeaderTimeout = timeout
+
+	return &http.Client{Transport: &idleTimeoutTransport{base: transport, timeout: timeout}}
+}
+
+// idleTimeoutTransport cancels requests whose response body doesn't make progress for longer than timeout
+type idleTimeoutTransport struct {
+	base    http.RoundTripper
+	timeout time.Duration
+}
+
+func (t *idleTimeoutTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx, cancel := context.WithCancelCause(req.Context())
+	resp, err := t.base.RoundTrip(req.WithContext(ctx))
+	if err != nil {
+		cancel(nil)
+		return nil, err
+	}
+
+	timeoutErr := fmt.Errorf("%w: no data for %s", ErrIdleTimeout, t.timeout)
+	resp.Body = &idleTimeoutBody{
+		ReadCloser: resp.Body,
+		ctx:        ctx,
+		cancel:     cancel,
+		timeout:    t.timeout,
+		timer:      time.AfterFunc(t.timeout, func() { cancel(timeoutErr) }),
+	}
+
+	return resp, nil
+}
+
+// idleTimeoutBody restarts the idle timer whenever part of the body arrives
+type idleTimeoutBody struct {
+	io.ReadCloser
+	ctx     context.Context
+	cancel  context.CancelCauseFunc
+	timeout time.Duration
+	timer   *time.Timer
+}
+
+func (b *idleTimeoutBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if n > 0 {
+		b.timer.Reset(b.timeout)
+	}
+	if err != nil && err != io.EOF && errors.Is(context.Cause(b.ctx), ErrIdleTimeout) {
+		err = context.Cause(b.ctx)
+	}
+
+	return n, err
+}
+
+func (b *idleTimeoutBody) Close() error {
+	b.timer.Stop()
+	b.cancel(nil)
+
+	return b.ReadCloser.Close()
+}
@@ -0,0 +1,67 @@
+package httpclient
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// newChunkedServer writes chunks of the body every delay, then blocks until the request is done if stall is set
+func newChunkedServer(t *testing.T, chunks int, delay time.Duration, stall bool) *httptest.Server {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for i := 0; i < chunks; i++ {
+			w.Write([]byte("chunk\n"))
+			w.(http.Flusher).Flush()
+			time.Sleep(delay)
+		}
+		if stall {
+			<-r.Context().Done()
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func TestSlowBodyCompletes(t *testing.T) {
+	// The whole body takes several timeouts, but every chunk arrives well within one
+	server := newChunkedServer(t, 8, 25*time.Millisecond, false)
+
+	resp, err := New(time.Second, 100*time.Millisecond).Get(server.URL)
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("reading body: error = %v", err)
+	}
+	if len(body) != 8*len("chunk\n") {
+		t.Errorf("got %d bytes, want %d", len(body), 8*len("chunk\n"))
+	}
+}
+
+func TestStalledBodyTimesOut(t *testing.T) {
+	server := newChunkedServer(t, 1, 0, true)
+
+	resp, err := New(time.Second, 100*time.Millisecond).Get(server.URL)
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	defer resp.Body.Close()
+
+	start := time.Now()
+	_, err = io.ReadAll(resp.Body)
+	if !errors.Is(err, ErrIdleTimeout) {
+		t.Errorf("reading body: error = %v, want %v", err, ErrIdleTimeout)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("stalled body took %s to fail", elapsed)
+	}
+}
@@ -1,12 +1,18 @@
 package types
 
+import "fmt"
+
 // PRResult represents the result of checking a PR
 type PRResult struct {
-	Number           int
-	ChangelogDesc    string
-	PRTitle          string
-	Status           PRStatus
-	Error            error
+	Number        int
+	ChangelogDesc string
+	PRTitle       string
+	Status        PRStatus
+	Error         error
+	Lines         []int  // Line numbers within the changelog file that reference the PR
+	LineNumber    int    // Line number in the changelog file of the entry the PR was checked against, 0 if not found
+	RawLine       string // Original text of that changelog entry
+	Explanation   string // Why the description and title differ, only set for explained potential mismatches
 }
 
 // PRStatus represents the status of a PR check
@@ -16,6 +22,15 @@ const (
 	StatusGoodMatch PRStatus = iota
 	StatusPotentialMismatch
 	StatusNotFound
+	StatusDuplicate
+	StatusNotMerged
+	StatusUncached
+	StatusLinkMismatch
+	StatusMissingPR
+	StatusMalformed
+	StatusInvalidComponent
+	StatusNotInRef
+	StatusError
 )
 
 func (s PRStatus) String() string {
@@ -26,7 +41,93 @@ func (s PRStatus) String() string {
 		return "⚠️ Potential mismatch"
 	case StatusNotFound:
 		return "❌ Not found"
+	case StatusDuplicate:
+		return "🔁 Duplicate"
+	case StatusNotMerged:
+		return "🚫 Not merged"
+	case StatusUncached:
+		return "💤 Not cached"
+	case StatusLinkMismatch:
+		return "🔗 Link mismatch"
+	case StatusMissingPR:
+		return "❔ Missing PR link"
+	case StatusMalformed:
+		return "🧩 Malformed entry"
+	case StatusInvalidComponent:
+		return "🏷️ Invalid component"
+	case StatusNotInRef:
+		return "📦 Not in release"
+	case StatusError:
+		return "💥 Error"
 	default:
 		return "Unknown status"
 	}
-}
\ No newline at end of file
+}
+
+// Emoji returns the emoji used to represent the status in reports
+func (s PRStatus) Emoji() string {
+	switch s {
+	case StatusGoodMatch:
+		return "✅"
+	case StatusPotentialMismatch:
+		return "⚠️"
+	case StatusNotFound:
+		return "❌"
+	case StatusDuplicate:
+		return "🔁"
+	case StatusNotMerged:
+		return "🚫"
+	case StatusUncached:
+		return "💤"
+	case StatusLinkMismatch:
+		return "🔗"
+	case StatusMissingPR:
+		return "❔"
+	case StatusMalformed:
+		return "🧩"
+	case StatusInvalidComponent:
+		return "🏷️"
+	case StatusNotInRef:
+		return "📦"
+	case StatusError:
+		return "💥"
+	default:
+		return "❓"
+	}
+}
+
+// Summary is the number of results per status of a changelog check
+type Summary struct {
+	Total  int
+	PRs    int // Distinct PRs among the results, entries without a PR and duplicate reports aren't counted
+	Counts map[PRStatus]int
+}
+
+// Summarize counts the results per status
+func Summarize(results []PRResult) Summary {
+	summary := Summary{
+		Total:  len(results),
+		Counts: make(map[PRStatus]int),
+	}
+	prs := make(map[int]bool)
+	for _, result := range results {
+		summary.Counts[result.Status]++
+		if result.Number > 0 {
+			prs[result.Number] = true
+		}
+	}
+	summary.PRs = len(prs)
+
+	return summary
+}
+
+// Count returns the number of results with the given status
+func (s Summary) Count(status PRStatus) int {
+	return s.Counts[status]
+}
+
+// String formats the summary line with the good matches, potential mismatches and PRs not found
+func (s Summary) String() string {
+	return fmt.Sprintf("✅ Good matches: %d | ⚠️ Potential mismatches: %d | ❌ Not found: %d",
+		s.Count(StatusGoodMatch), s.Count(StatusPotentialMismatch), s.Count(StatusNotFound))
+}
@@ -0,0 +1,262 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// channelRecord is a single channel read back from an output file
+type channelRecord struct {
+	ChainPath string
+	ChannelID string
+	State     string
+	Version   string
+}
+
+func (r channelRecord) key() string {
+	return r.ChainPath + "/" + r.ChannelID
+}
+
+// channelChange is a channel present in both runs whose state or version changed
+type channelChange struct {
+	Old channelRecord
+	New channelRecord
+}
+
+// ChannelDiff is the difference between two runs, keyed by chain path and channel ID
+type ChannelDiff struct {
+	Added   []channelRecord
+	Removed []channelRecord
+	Changed []channelChange
+	// Failed are the chains whose channels couldn't be fetched in either run, their missing channels aren't
+	// reported as added or removed since they are most likely still there
+	Failed []string
+}
+
+// failedChainRegex matches the error line written for a chain whose channels couldn't be fetched, a "#" comment in csv
+var failedChainRegex = regexp.MustCompile(`^(?:# )?Failed to fetch channels for chain (\S+): `)
+
+// DiffChannelVersions reads two output files, csv or plain, and returns the channels that were added, removed or
+// whose state or version changed between them
+func DiffChannelVersions(oldFileName, newFileName string) (ChannelDiff, error) {
+	oldRecords, oldFailed, err := readChannelVersions(oldFileName)
+	if err != nil {
+		return ChannelDiff{}, fmt.Errorf("failed to read %s: %w", oldFileName, err)
+	}
+	newRecords, newFailed, err := readChannelVersions(newFileName)
+	if err != nil {
+		return ChannelDiff{}, fmt.Errorf("failed to read %s: %w", newFileName, err)
+	}
+
+	var diff ChannelDiff
+	for key, newRecord := range newRecords {
+		oldRecord, found := oldRecords[key]
+		switch {
+		case !found && oldFailed[newRecord.ChainPath]:
+			// Most likely only missing from the old run because the fetch failed
+		case !found:
+			diff.Added = append(diff.Added, newRecord)
+		case oldRecord.State != newRecord.State || oldRecord.Version != newRecord.Version:
+			diff.Changed = append(diff.Changed, channelChange{Old: oldRecord, New: newRecord})
+		}
+	}
+	for key, oldRecord := range oldRecords {
+		if _, found := newRecords[key]; !found && !newFailed[oldRecord.ChainPath] {
+			diff.Removed = append(diff.Removed, oldRecord)
+		}
+	}
+
+	failed := make(map[string]bool)
+	for chainPath := range oldFailed {
+		failed[chainPath] = true
+	}
+	for chainPath := range newFailed {
+		failed[chainPath] = true
+	}
+	for chainPath := range failed {
+		diff.Failed = append(diff.Failed, chainPath)
+	}
+	sort.Strings(diff.Failed)
+
+	sort.Slice(diff.Added, func(i, j int) bool { return diff.Added[i].key() < diff.Added[j].key() })
+	sort.Slice(diff.Removed, func(i, j int) bool { return diff.Removed[i].key() < diff.Removed[j].key() })
+	sort.Slice(diff.Changed, func(i, j int) bool { return diff.Changed[i].New.key() < diff.Changed[j].New.key() })
+
+	return diff, nil
+}
+
+// readChannelVersions reads an output file into records keyed by chain path and channel ID, along with the chains
+// whose channels couldn't be fetched. Files ending in .csv are read as csv, anything else as the plain format
+func readChannelVersions(fileName string) (map[string]channelRecord, map[string]bool, error) {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer file.Close()
+
+	if strings.HasSuffix(fileName, ".csv") {
+		return readChannelVersionsCSV(file)
+	}
+	return readChannelVersionsPlain(file)
+}
+
+func readChannelVersionsCSV(r io.Reader) (map[string]channelRecord, map[string]bool, error) {
+	content, err := io.ReadAll(r)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// The csv reader skips comments, so find the failed chains in the error comments first
+	failed := make(map[string]bool)
+	for _, line := range strings.Split(string(content), "\n") {
+		if match := failedChainRegex.FindStringSubmatch(line); match != nil {
+			failed[match[1]] = true
+		}
+	}
+
+	reader := csv.NewReader(strings.NewReader(string(content)))
+	// Skips the partial run markers and errors
+	reader.Comment = '#'
+	// Older runs have fewer columns
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read header: %w", err)
+	}
+	columns := make(map[string]int)
+	for i, name := range header {
+		columns[name] = i
+	}
+	for _, name := range []string{"chain_path", "channel_id", "state", "version"} {
+		if _, ok := columns[name]; !ok {
+			return nil, nil, fmt.Errorf("missing column %s", name)
+		}
+	}
+
+	records := make(map[string]channelRecord)
+	for {
+		row, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+
+		record := channelRecord{
+			ChainPath: row[columns["chain_path"]],
+			ChannelID: row[columns["channel_id"]],
+			State:     row[columns["state"]],
+			Version:   row[columns["version"]],
+		}
+		records[record.key()] = record
+	}
+
+	return records, failed, nil
+}
+
+func readChannelVersionsPlain(r io.Reader) (map[string]channelRecord, map[string]bool, error) {
+	records := make(map[string]channelRecord)
+	failed := make(map[string]bool)
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if match := failedChainRegex.FindStringSubmatch(line); match != nil {
+			failed[match[1]] = true
+			continue
+		}
+		// Skip the partial run marker and any other error lines
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, "Failed to fetch") {
+			continue
+		}
+
+		fields := strings.Split(line, ", ")
+		if len(fields) < 4 {
+			continue
+		}
+
+		record := channelRecord{ChainPath: fields[0], ChannelID: fields[1], State: fields[2], Version: fields[3]}
+		records[record.key()] = record
+	}
+
+	return records, failed, scanner.Err()
+}
+
+// writeChannelDiff writes a readable report of the diff, starting with a summary of the versions channels moved to, e.g.
+//
+//	12 channels upgraded to ics20-2
+func writeChannelDiff(w io.Writer, diff ChannelDiff) error {
+	// Number of changed channels per new version, only counting channels whose version changed
+	upgrades := make(map[string]int)
+	for _, change := range diff.Changed {
+		if change.Old.Version != change.New.Version {
+			upgrades[change.New.Version]++
+		}
+	}
+	versions := make([]string, 0, len(upgrades))
+	for version := range upgrades {
+		versions = append(versions, version)
+	}
+	sort.Slice(versions, func(i, j int) bool {
+		if upgrades[versions[i]] != upgrades[versions[j]] {
+			return upgrades[versions[i]] > upgrades[versions[j]]
+		}
+		return versions[i] < versions[j]
+	})
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d added, %d removed, %d changed", len(diff.Added), len(diff.Removed), len(diff.Changed))
+	if len(diff.Failed) > 0 {
+		fmt.Fprintf(&b, ", %d chains failed to fetch", len(diff.Failed))
+	}
+	b.WriteString("\n")
+	for _, version := range versions {
+		fmt.Fprintf(&b, "%d channels upgraded to %s\n", upgrades[version], version)
+	}
+
+	if len(diff.Added) > 0 {
+		b.WriteString("\nAdded:\n")
+		for _, record := range diff.Added {
+			fmt.Fprintf(&b, "  %s %s: %s, %s\n", record.ChainPath, record.ChannelID, record.State, record.Version)
+		}
+	}
+
+	if len(diff.Removed) > 0 {
+		b.WriteString("\nRemoved:\n")
+		for _, record := range diff.Removed {
+			fmt.Fprintf(&b, "  %s %s: %s, %s\n", record.ChainPath, record.ChannelID, record.State, record.Version)
+		}
+	}
+
+	if len(diff.Failed) > 0 {
+		b.WriteString("\nFailed to fetch, missing channels not counted as added or removed:\n")
+		for _, chainPath := range diff.Failed {
+			fmt.Fprintf(&b, "  %s\n", chainPath)
+		}
+	}
+
+	if len(diff.Changed) > 0 {
+		b.WriteString("\nChanged:\n")
+		for _, change := range diff.Changed {
+			fmt.Fprintf(&b, "  %s %s:", change.New.ChainPath, change.New.ChannelID)
+			if change.Old.State != change.New.State {
+				fmt.Fprintf(&b, " state %s -> %s", change.Old.State, change.New.State)
+			}
+			if change.Old.Version != change.New.Version {
+				fmt.Fprintf(&b, " version %s -> %s", change.Old.Version, change.New.Version)
+			}
+			b.WriteString("\n")
+		}
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
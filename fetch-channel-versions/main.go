@@ -1,22 +1,73 @@
 package main
 
 import (
+	"bufio"
+	"context"
+	"encoding/csv"
 	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
-	"io"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"syscall"
 	"time"
+
+	"github.com/gjermundgaraba/scripts/httpx"
 )
 
+const cacheDir = "out/cache"
+
+// Fetcher fetches chains and their channels, sending all requests through the same http.Client
+type Fetcher struct {
+	client *httpx.Client
+
+	mu sync.Mutex
+	// Counterparty chain ID per chain path and connection ID, so each connection is only resolved once
+	counterpartyChainIDs map[string]string
+	// Chain path per chain ID, from the chain directory
+	chainPaths map[string]string
+}
+
+// NewFetcher creates a fetcher spacing out requests to the same chain by at least interval, and requests to the same
+// host by at least hostInterval, since every chain is reached through the same rest.cosmos.directory proxy
+func NewFetcher(httpClient *http.Client, interval, hostInterval time.Duration) *Fetcher {
+	client := httpx.NewClient(httpClient, interval, 5)
+	client.KeyFunc = httpx.FirstPathSegmentKey
+	client.SetSharedHostInterval(hostInterval)
+
+	return &Fetcher{
+		client:               client,
+		counterpartyChainIDs: make(map[string]string),
+		chainPaths:           make(map[string]string),
+	}
+}
+
 type Chain struct {
-	Path string `json:"path"`
+	Path    string `json:"path"`
+	ChainID string `json:"chain_id"`
 
 	baseUrl string // Optional and only used when fetching for a specific chain
 }
 
+const (
+	chainDirectoryURL = "https://chains.cosmos.directory"
+	// The directory lists every chain with its full metadata, so it's big, but anything past this is not the directory
+	chainDirectoryMaxBytes = 64 << 20
+	// Covers all retries, including the backoff between them
+	chainDirectoryTimeout = 2 * time.Minute
+	// Shared by both fetch tools, so running one right after the other only downloads the directory once
+	chainDirectoryCacheFile = "out/cache/chain_directory.json"
+	chainDirectoryCacheTTL  = time.Hour
+)
+
 // ChainDirectoryResponse represents the structure of the response from https://chains.cosmos.directory
 type ChainDirectoryResponse struct {
 	Chains []Chain `json:"chains"`
@@ -24,12 +75,7 @@ type ChainDirectoryResponse struct {
 
 // ChannelResponse represents the structure of the IBC channels query response
 type ChannelResponse struct {
-	Channels []struct {
-		// We'll only parse out the fields we need. The complete response has more fields.
-		Version   string `json:"version"`
-		ChannelID string `json:"channel_id"`
-		State     string `json:"state"`
-	} `json:"channels"`
+	Channels []Channel `json:"channels"`
 	// Pagination can be helpful if you want to check "total" or "next_key"
 	Pagination struct {
 		NextKey interface{} `json:"next_key"`
@@ -37,6 +83,55 @@ type ChannelResponse struct {
 	} `json:"pagination"`
 }
 
+type Channel struct {
+	// We'll only parse out the fields we need. The complete response has more fields.
+	Version      string `json:"version"`
+	ChannelID    string `json:"channel_id"`
+	PortID       string `json:"port_id"`
+	State        string `json:"state"`
+	Counterparty struct {
+		PortID    string `json:"port_id"`
+		ChannelID string `json:"channel_id"`
+	} `json:"counterparty"`
+	ConnectionHops []string `json:"connection_hops"`
+	// Ordering is ORDER_ORDERED or ORDER_UNORDERED
+	Ordering string `json:"ordering"`
+	// UpgradeSequence counts the channel upgrades, only returned by chains with channel upgradability (ibc-go v8.1+)
+	UpgradeSequence string `json:"upgrade_sequence"`
+}
+
+// UpgradeInProgress reports whether the channel is in the middle of an upgrade, which pauses it in a flushing state
+func (ch Channel) UpgradeInProgress() bool {
+	switch normalizeState(ch.State) {
+	case "FLUSHING", "FLUSHCOMPLETE":
+		return true
+	default:
+		return false
+	}
+}
+
+// cacheVersion is bumped whenever the cached Channel fields change, so old cache entries are refetched
+const cacheVersion = 3
+
+// ChainCache is the on-disk cache entry for a single chain's channels
+type ChainCache struct {
+	Version  int       `json:"version"`
+	Path     string    `json:"path"`
+	Complete bool      `json:"complete"` // True once paging reached next_key == nil
+	Channels []Channel `json:"channels"`
+}
+
+// ConnectionClientStateResponse represents the structure of the IBC connection client state query response
+type ConnectionClientStateResponse struct {
+	IdentifiedClientState struct {
+		ClientID    string `json:"client_id"`
+		ClientState struct {
+			// Only set for client types that track a chain ID, e.g. tendermint
+			ChainID string `json:"chain_id"`
+		} `json:"client_state"`
+	} `json:"identified_client_state"`
+}
+
 type ChannelVersion struct {
 	AppVersion string `json:"app_version"`
 	FeeVersion string `json:"fee_version"`
@@ -44,16 +139,78 @@ type ChannelVersion struct {
 }
 
 func main() {
+	limitChains := flag.Int("limit-chains", 0, "Only process the first N chains from the chain directory, 0 processes all of them")
+	refresh := flag.Bool("refresh", false, "Ignore cached channel data and fetch every chain again")
+	format := flag.String("format", "csv", "Output format: csv or plain (legacy comma separated lines)")
+	stateFilter := flag.String("state", "", "Comma separated channel states to include, e.g. OPEN,CLOSED (default all states)")
+	workers := flag.Int("workers", 4, "Number of chains to process concurrently")
+	interval := flag.Duration("interval", 500*time.Millisecond, "Minimum time between requests to the same chain")
+	hostInterval := flag.Duration("host-interval", 100*time.Millisecond, "Minimum time between requests to the same host across all chains, e.g. rest.cosmos.directory")
+	maxFailures := flag.Int("max-failures", 10, "Skip a chain's endpoint for the rest of the run after this many consecutive failed requests (0 never skips)")
+	skip := flag.String("skip", "", "Comma separated chain paths to skip, e.g. chains that always time out")
+	only := flag.String("only", "", "Comma separated chain paths to process, skipping every other chain in the directory")
+	resolveCounterparty := flag.Bool("resolve-counterparty", false, "Resolve the counterparty chain of every channel from its client state (slow, one request per connection)")
+	network := flag.String("network", "tcp", "Network to dial nodes over: tcp (IPv4 or IPv6), tcp4 (IPv4 only) or tcp6 (IPv6 only)")
+	dnsServer := flag.String("dns-server", "", "host:port of a DNS server to resolve nodes with instead of the system resolver, e.g. an internal resolver")
+	insecure := flag.Bool("insecure", false, "Skip TLS certificate verification, for local nodes with self-signed certificates (unsafe)")
+	caCert := flag.String("ca-cert", "", "PEM file with extra CA certificates to trust, e.g. the CA of an internal testnet")
+	refreshChains := flag.Bool("refresh-chains", false, "Download the chain directory again even if the cached copy is less than an hour old")
+	diffRuns := flag.Bool("diff", false, "Compare two output files instead of fetching, usage: -diff <old file> <new file>")
+	flag.Parse()
+	args := flag.Args()
+
+	if *diffRuns {
+		if len(args) != 2 {
+			log.Fatalf("-diff takes exactly two output files, the old run and the new run")
+		}
+
+		diff, err := DiffChannelVersions(args[0], args[1])
+		if err != nil {
+			log.Fatalf("Failed to diff channel versions: %v", err)
+		}
+		if err := writeChannelDiff(os.Stdout, diff); err != nil {
+			log.Fatalf("Failed to write diff: %v", err)
+		}
+		return
+	}
+
+	if *workers < 1 {
+		log.Fatalf("-workers must be at least 1")
+	}
+
+	// Cancel the context on Ctrl-C so we can still write what we have collected so far
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	httpClient, err := httpx.NewHTTPClient(httpx.TransportConfig{
+		Network:    *network,
+		DNSServer:  *dnsServer,
+		CACertFile: *caCert,
+		Insecure:   *insecure,
+	})
+	if err != nil {
+		log.Fatalf("Invalid transport config: %v", err)
+	}
+	if *insecure {
+		log.Println("WARNING: -insecure is set, TLS certificates are not verified and responses could come from anyone on the network")
+	}
+	fetcher := NewFetcher(httpClient, *interval, *hostInterval)
+	fetcher.client.SetMaxFailures(*maxFailures)
+
+	if err := os.MkdirAll(cacheDir, 0755); err != nil {
+		log.Fatalf("Failed to create cache directory: %v", err)
+	}
+
 	// 1. Fetch the list of chains (or use the provided chain argument)
 	var chains []Chain
-	if len(os.Args) > 1 {
-		chainPath := os.Args[1]
+	if len(args) > 0 {
+		chainPath := args[0]
 
 		fmt.Println("Chain argument provided, will only fetch channels for chain:", chainPath)
 
 		baseUrl := ""
-		if len(os.Args) > 2 {
-			baseUrl = os.Args[2]
+		if len(args) > 1 {
+			baseUrl = args[1]
 		}
 
 		fmt.Println("Base URL override provided:", baseUrl)
@@ -61,96 +218,493 @@ func main() {
 		chains = []Chain{{Path: chainPath, baseUrl: baseUrl}}
 	} else {
 		var err error
-		chains, err = fetchChains()
+		chains, err = fetcher.fetchChains(ctx, *refreshChains)
 		if err != nil {
 			log.Fatalf("Failed to fetch chains: %v", err)
 		}
+		fetcher.SetChainDirectory(chains)
+
+		chains = filterChains(chains, parseChainList(*skip), parseChainList(*only))
+
+		if *limitChains > 0 && *limitChains < len(chains) {
+			fmt.Printf("Limiting to the first %d of %d chains\n", *limitChains, len(chains))
+			chains = chains[:*limitChains]
+		}
 	}
 
 	// Create/Truncate the output file
-	file, err := os.Create("out/channel_versions.txt")
+	var fileName string
+	switch *format {
+	case "csv":
+		fileName = "out/channel_versions.csv"
+	case "plain":
+		fileName = "out/channel_versions.txt"
+	default:
+		log.Fatalf("Unknown output format: %s", *format)
+	}
+
+	file, err := os.Create(fileName)
 	if err != nil {
 		log.Fatalf("Failed to create output file: %v", err)
 	}
 	defer file.Close()
 
-	// 2. For each chain, fetch all IBC channels in pages of 50
-	for _, chain := range chains {
-		offset := 0
-		for {
-			channels, err := fetchIBCChannels(chain, offset, 50)
-			if err != nil {
-				errorMsg := fmt.Sprintf("Failed to fetch channels for chain %s: %v", chain.Path, err)
-				log.Println(errorMsg)
-				_, _ = file.WriteString(errorMsg + "\n")
-
-				break // Move on to the next chain
+	var out outputWriter = newPlainWriter(file)
+	if *format == "csv" {
+		out = newCSVWriter(file)
+	}
+	if err := out.WriteHeader(); err != nil {
+		log.Fatalf("Failed to write output header: %v", err)
+	}
+
+	states := parseStateFilter(*stateFilter)
+
+	// Memory use is bounded by the largest chains rather than the whole directory: only the channels of the chains
+	// being worked on or waiting to be written are held (they are needed for their cache files), rows are streamed to
+	// the output and flushed after every chain, and the only state kept across chains are these maps with one entry per
+	// distinct app version and channel state.
+	versionCounts := make(map[string]int)
+	stateCounts := make(map[string]int)
+
+	// 2. Fetch the IBC channels of several chains concurrently (or load them from the cache)
+	// Requests are spaced out per chain, so workers on different chains don't slow each other down
+	process := func(chain Chain) chainResult {
+		result := chainResult{chain: chain}
+
+		var channels []Channel
+		cached, err := loadChainCache(chain.Path)
+		if err != nil {
+			log.Printf("Failed to read cache for chain %s: %v", chain.Path, err)
+		}
+
+		if !*refresh && cached != nil && cached.Complete {
+			fmt.Printf("Using %d cached channels for chain %s\n", len(cached.Channels), chain.Path)
+			channels = cached.Channels
+		} else {
+			channels, err = fetcher.fetchAllIBCChannels(ctx, chain)
+			if err != nil && ctx.Err() == nil {
+				result.errorMsg = fmt.Sprintf("Failed to fetch channels for chain %s: %v", chain.Path, err)
+				log.Println(result.errorMsg)
+			}
+
+			if err := saveChainCache(ChainCache{Version: cacheVersion, Path: chain.Path, Complete: err == nil, Channels: channels}); err != nil {
+				log.Printf("Failed to write cache for chain %s: %v", chain.Path, err)
 			}
-			if len(channels.Channels) == 0 {
-				// No more channels found, break out of paging loop
-				break
+		}
+
+		for _, ch := range channels {
+			if !matchesStateFilter(states, ch.State) {
+				continue
 			}
 
-			// 3. Write every channel version to our file
-			for _, ch := range channels.Channels {
-				version := ch.Version
-				var feeVersion string
-				if strings.HasPrefix(ch.Version, "{") {
-					var versionStruct ChannelVersion
-					if err := json.Unmarshal([]byte(ch.Version), &versionStruct); err != nil {
-						panic(err)
-					}
-					version = versionStruct.Version
-					if version == "" {
-						version = versionStruct.AppVersion
-					}
-
-					feeVersion = versionStruct.FeeVersion
+			row := channelRow{ChainPath: chain.Path, Channel: ch}
+			row.Version, row.FeeVersion, row.Middleware = ClassifyVersion(ch.Version)
+
+			if *resolveCounterparty && ctx.Err() == nil {
+				row.CounterpartyChain, err = fetcher.ResolveCounterpartyChain(ctx, chain, ch)
+				if err != nil {
+					log.Printf("Failed to resolve counterparty chain of %s on chain %s: %v", ch.ChannelID, chain.Path, err)
 				}
+			}
+
+			result.rows = append(result.rows, row)
+		}
+
+		result.complete = ctx.Err() == nil
+		return result
+	}
 
-				_, _ = file.WriteString(fmt.Sprintf("%s, %s, %s, %s, %s\n", chain.Path, ch.ChannelID, ch.State, version, feeVersion))
+	jobs := make(chan Chain)
+	results := make(chan chainResult)
+	var wg sync.WaitGroup
+	for range *workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for chain := range jobs {
+				results <- process(chain)
+			}
+		}()
+	}
 
+	go func() {
+	dispatch:
+		for _, chain := range chains {
+			select {
+			case jobs <- chain:
+			case <-ctx.Done():
+				break dispatch
 			}
+		}
+		close(jobs)
+		wg.Wait()
+		close(results)
+	}()
+
+	// 3. Write every channel version to our file, only this goroutine writes so chains never interleave
+	processed := 0
+	for result := range results {
+		if result.errorMsg != "" {
+			_ = out.WriteError(result.errorMsg)
+		}
 
-			// If we got fewer than 50 in this batch, we assume there are no more
-			if len(channels.Channels) < 50 {
-				break
+		for _, row := range result.rows {
+			versionCounts[row.Version]++
+			stateCounts[row.Channel.State]++
+			if err := out.WriteChannel(row); err != nil {
+				log.Fatalf("Failed to write output: %v", err)
 			}
-			offset += 50
 		}
+
+		// Flush every chain, so an interrupted or crashed run still leaves all finished chains in the file
+		if err := out.Flush(); err != nil {
+			log.Fatalf("Failed to write output: %v", err)
+		}
+
+		if result.complete {
+			processed++
+		}
+	}
+
+	interrupted := ctx.Err() != nil
+	if interrupted {
+		msg := fmt.Sprintf("partial run: interrupted after %d of %d chains", processed, len(chains))
+		log.Println(msg)
+		if err := out.WritePartial(msg); err != nil {
+			log.Fatalf("Failed to write output: %v", err)
+		}
+	}
+
+	if err := out.Flush(); err != nil {
+		log.Fatalf("Failed to write output: %v", err)
+	}
+
+	countsFileName := "out/channel_version_counts.txt"
+	if err := writeCounts(countsFileName, versionCounts); err != nil {
+		log.Fatalf("Failed to write version counts: %v", err)
+	}
+
+	stateCountsFileName := "out/channel_state_counts.txt"
+	if err := writeCounts(stateCountsFileName, stateCounts); err != nil {
+		log.Fatalf("Failed to write state counts: %v", err)
+	}
+	fmt.Printf("Channels by state: %s (see %s)\n", formatCounts(stateCounts), stateCountsFileName)
+
+	if skippedHosts := fetcher.client.SkippedHosts(); len(skippedHosts) > 0 {
+		fmt.Printf("Skipped %d hosts for the rest of the run after %d consecutive failures: %s\n", len(skippedHosts), *maxFailures, strings.Join(skippedHosts, ", "))
+	}
+
+	if interrupted {
+		fmt.Println("Interrupted! Wrote partial channel versions to", fileName, "and version counts to", countsFileName)
+		fmt.Println("Run again to resume, completed chains are served from the cache")
+		return
 	}
 
-	fmt.Println("Done! Wrote channel versions to channel_versions.txt")
+	fmt.Println("Done! Wrote channel versions to", fileName, "and version counts to", countsFileName)
 }
 
-// fetchChains fetches the list of chains from https://chains.cosmos.directory
-func fetchChains() ([]Chain, error) {
-	resp, err := http.Get("https://chains.cosmos.directory")
+// writeCounts writes one "key: count" line per key, e.g. per version or channel state, most common first
+func writeCounts(fileName string, counts map[string]int) error {
+	file, err := os.Create(fileName)
 	if err != nil {
-		return nil, fmt.Errorf("GET error: %w", err)
+		return err
 	}
-	defer resp.Body.Close()
+	defer file.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("unexpected status: %s", resp.Status)
+	for _, key := range sortedByCount(counts) {
+		if _, err := file.WriteString(fmt.Sprintf("%s: %d\n", key, counts[key])); err != nil {
+			return err
+		}
 	}
 
-	bodyBytes, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("error reading response body: %w", err)
+	return nil
+}
+
+// formatCounts formats counts on a single line, e.g. "STATE_OPEN 120, STATE_CLOSED 4", most common first
+func formatCounts(counts map[string]int) string {
+	parts := make([]string, 0, len(counts))
+	for _, key := range sortedByCount(counts) {
+		parts = append(parts, fmt.Sprintf("%s %d", key, counts[key]))
+	}
+
+	return strings.Join(parts, ", ")
+}
+
+// sortedByCount returns the keys of counts with the highest count first, ties sorted by key
+func sortedByCount(counts map[string]int) []string {
+	keys := make([]string, 0, len(counts))
+	for key := range counts {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if counts[keys[i]] != counts[keys[j]] {
+			return counts[keys[i]] > counts[keys[j]]
+		}
+		return keys[i] < keys[j]
+	})
+
+	return keys
+}
+
+// normalizeState turns "STATE_OPEN", "open" and "OPEN" into "OPEN"
+func normalizeState(state string) string {
+	return strings.TrimPrefix(strings.ToUpper(strings.TrimSpace(state)), "STATE_")
+}
+
+// parseStateFilter parses a comma separated list of states, returning nil if no filter is set
+func parseStateFilter(filter string) map[string]bool {
+	if filter == "" {
+		return nil
+	}
+
+	states := make(map[string]bool)
+	for _, state := range strings.Split(filter, ",") {
+		if state = normalizeState(state); state != "" {
+			states[state] = true
+		}
+	}
+
+	return states
+}
+
+// matchesStateFilter checks if the channel state is included by the filter, an empty filter matches everything
+func matchesStateFilter(states map[string]bool, state string) bool {
+	return len(states) == 0 || states[normalizeState(state)]
+}
+
+// ClassifyVersion splits a raw channel version into the app version, fee version and the middleware wrapping the app
+// Plain versions like "ics20-1" are returned as they are. Fee middleware channels have a JSON-wrapped version,
+// e.g. {"fee_version":"ics29-1","app_version":"ics20-1"}, and interchain accounts channels a JSON metadata
+// version, e.g. {"version":"ics27-1","encoding":"proto3",...}, which may itself be wrapped by the fee middleware.
+// Versions that look like JSON but can't be parsed are returned as the app version
+func ClassifyVersion(raw string) (appVersion, feeVersion, middleware string) {
+	raw = strings.TrimSpace(raw)
+	if !strings.HasPrefix(raw, "{") {
+		return raw, "", ""
+	}
+
+	var versionStruct ChannelVersion
+	if err := json.Unmarshal([]byte(raw), &versionStruct); err != nil {
+		return raw, "", ""
+	}
+
+	switch {
+	case versionStruct.FeeVersion != "":
+		// The wrapped app version can be JSON metadata too, e.g. fee enabled interchain accounts
+		appVersion, _, _ = ClassifyVersion(versionStruct.AppVersion)
+		return appVersion, versionStruct.FeeVersion, "fee"
+	case versionStruct.Version != "":
+		return versionStruct.Version, "", ""
+	case versionStruct.AppVersion != "":
+		return ClassifyVersion(versionStruct.AppVersion)
+	default:
+		return raw, "", ""
+	}
+}
+
+// outputWriter writes the channel versions to the output file
+type outputWriter interface {
+	WriteHeader() error
+	WriteChannel(row channelRow) error
+	WriteError(msg string) error
+	WritePartial(msg string) error
+	Flush() error
+}
+
+// chainResult is what a worker found for a single chain, written to the output by the main goroutine
+type chainResult struct {
+	chain Chain
+	rows  []channelRow
+	// errorMsg is set if the channels couldn't be fetched, rows then has whatever was fetched before the error
+	errorMsg string
+	// complete is false if the run was interrupted while the chain was being processed
+	complete bool
+}
+
+// channelRow is a single channel in the output, along with its parsed version
+type channelRow struct {
+	ChainPath  string
+	Channel    Channel
+	Version    string
+	FeeVersion string
+	Middleware string
+
+	// CounterpartyChain is only resolved with -resolve-counterparty
+	CounterpartyChain string
+}
+
+// plainWriter writes the legacy "chain, channel, state, version, feeVersion" lines, the other columns are only in the CSV
+// Lines are buffered, so Flush must be called for them to reach the file
+type plainWriter struct {
+	w *bufio.Writer
+}
+
+func newPlainWriter(file *os.File) *plainWriter {
+	return &plainWriter{w: bufio.NewWriter(file)}
+}
+
+func (w *plainWriter) WriteHeader() error {
+	return nil
+}
+
+func (w *plainWriter) WriteChannel(row channelRow) error {
+	_, err := fmt.Fprintf(w.w, "%s, %s, %s, %s, %s\n", row.ChainPath, row.Channel.ChannelID, row.Channel.State, row.Version, row.FeeVersion)
+	return err
+}
+
+func (w *plainWriter) WriteError(msg string) error {
+	_, err := w.w.WriteString(msg + "\n")
+	return err
+}
+
+func (w *plainWriter) WritePartial(msg string) error {
+	_, err := w.w.WriteString("# " + msg + "\n")
+	return err
+}
+
+func (w *plainWriter) Flush() error {
+	return w.w.Flush()
+}
+
+// csvWriter writes properly quoted CSV with a header row
+type csvWriter struct {
+	file *os.File
+	w    *csv.Writer
+}
+
+func newCSVWriter(file *os.File) *csvWriter {
+	return &csvWriter{file: file, w: csv.NewWriter(file)}
+}
+
+func (w *csvWriter) WriteHeader() error {
+	return w.w.Write([]string{
+		"chain_path", "channel_id", "state", "version", "fee_version",
+		"port_id", "counterparty_port_id", "counterparty_channel_id", "connection_hops", "middleware", "counterparty_chain",
+		"ordering", "upgrade_in_progress",
+	})
+}
+
+func (w *csvWriter) WriteChannel(row channelRow) error {
+	ch := row.Channel
+	return w.w.Write([]string{
+		row.ChainPath, ch.ChannelID, ch.State, row.Version, row.FeeVersion,
+		ch.PortID, ch.Counterparty.PortID, ch.Counterparty.ChannelID, strings.Join(ch.ConnectionHops, ";"),
+		row.Middleware, row.CounterpartyChain,
+		ch.Ordering, strconv.FormatBool(ch.UpgradeInProgress()),
+	})
+}
+
+// WriteError writes the error as a "#" comment line like WritePartial, so the output shows which chains failed
+func (w *csvWriter) WriteError(msg string) error {
+	return w.writeComment(msg)
+}
+
+// WritePartial writes the marker as a "#" comment line, readers can skip it with csv.Reader.Comment
+func (w *csvWriter) WritePartial(msg string) error {
+	return w.writeComment(msg)
+}
+
+// writeComment writes msg on a single "#" comment line after the buffered rows
+func (w *csvWriter) writeComment(msg string) error {
+	w.w.Flush()
+	if err := w.w.Error(); err != nil {
+		return err
+	}
+	_, err := w.file.WriteString("# " + strings.ReplaceAll(msg, "\n", " ") + "\n")
+	return err
+}
+
+func (w *csvWriter) Flush() error {
+	w.w.Flush()
+	return w.w.Error()
+}
+
+// fetchChains fetches the list of chains from https://chains.cosmos.directory
+// The directory is served from the on-disk cache while it is fresh, unless refresh is set
+func (f *Fetcher) fetchChains(ctx context.Context, refresh bool) ([]Chain, error) {
+	ctx, cancel := context.WithTimeout(ctx, chainDirectoryTimeout)
+	defer cancel()
+
+	ttl := chainDirectoryCacheTTL
+	if refresh {
+		ttl = 0
 	}
 
 	var chainResp ChainDirectoryResponse
-	if err := json.Unmarshal(bodyBytes, &chainResp); err != nil {
-		return nil, fmt.Errorf("JSON unmarshal error: %w", err)
+	if err := f.client.GetJSONCached(ctx, chainDirectoryURL, &chainResp, chainDirectoryMaxBytes, chainDirectoryCacheFile, ttl); err != nil {
+		return nil, fmt.Errorf("failed to fetch the chain directory from %s: %w", chainDirectoryURL, err)
 	}
 
 	return chainResp.Chains, nil
 }
 
+// cachePath returns the cache file location for a chain path
+func cachePath(chainPath string) string {
+	return filepath.Join(cacheDir, strings.ReplaceAll(chainPath, "/", "_")+".json")
+}
+
+// loadChainCache reads the cache entry for a chain, returning nil if there is none
+func loadChainCache(chainPath string) (*ChainCache, error) {
+	bz, err := os.ReadFile(cachePath(chainPath))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var cache ChainCache
+	if err := json.Unmarshal(bz, &cache); err != nil {
+		return nil, fmt.Errorf("JSON unmarshal error: %w", err)
+	}
+
+	// Written by an older version of this tool with fewer channel fields
+	if cache.Version != cacheVersion {
+		return nil, nil
+	}
+
+	return &cache, nil
+}
+
+// saveChainCache writes the cache entry for a chain, replacing any existing one
+func saveChainCache(cache ChainCache) error {
+	bz, err := json.Marshal(cache)
+	if err != nil {
+		return err
+	}
+
+	// Write to a temp file first so a crash never leaves a half-written entry behind
+	tmpPath := cachePath(cache.Path) + ".tmp"
+	if err := os.WriteFile(tmpPath, bz, 0644); err != nil {
+		return err
+	}
+
+	return os.Rename(tmpPath, cachePath(cache.Path))
+}
+
+// fetchAllIBCChannels pages through all channels for a chain until next_key is nil.
+// On error it returns the channels fetched so far along with the error.
+func (f *Fetcher) fetchAllIBCChannels(ctx context.Context, chain Chain) ([]Channel, error) {
+	var all []Channel
+	offset := 0
+	for {
+		channels, err := f.fetchIBCChannels(ctx, chain, offset, 50)
+		if err != nil {
+			return all, err
+		}
+
+		all = append(all, channels.Channels...)
+
+		if channels.Pagination.NextKey == nil || len(channels.Channels) == 0 {
+			// No more channels found, we are done paging
+			return all, nil
+		}
+		offset += len(channels.Channels)
+	}
+}
+
 // fetchIBCChannels fetches a page of up to `limit` channels for a given chain path
 // using the REST endpoint at rest.cosmos.directory/{chainPath}.
-func fetchIBCChannels(chain Chain, offset, limit int) (*ChannelResponse, error) {
+func (f *Fetcher) fetchIBCChannels(ctx context.Context, chain Chain, offset, limit int) (*ChannelResponse, error) {
 	baseUrl := fmt.Sprintf("https://rest.cosmos.directory/%s", chain.Path)
 	if chain.baseUrl != "" {
 		baseUrl = chain.baseUrl
@@ -158,50 +712,102 @@ func fetchIBCChannels(chain Chain, offset, limit int) (*ChannelResponse, error)
 
 	url := fmt.Sprintf("%s/ibc/core/channel/v1/channels?pagination.limit=%d&pagination.offset=%d", baseUrl, limit, offset)
 
-	var resp *http.Response
-	var err error
-	if err := retryWithBackoff(5, func() error {
-		resp, err = http.Get(url)
-		if err != nil {
-			return fmt.Errorf("GET error: %w", err)
-		}
+	var channels ChannelResponse
+	if err := f.client.GetJSON(ctx, url, &channels); err != nil {
+		return nil, fmt.Errorf("chainPath=%s: %w", chain.Path, err)
+	}
+
+	fmt.Printf("Fetched %d channels for chain %s\n", len(channels.Channels), chain.Path)
+
+	return &channels, nil
+}
+
+// SetChainDirectory sets the chains used to turn counterparty chain IDs into chain paths
+func (f *Fetcher) SetChainDirectory(chains []Chain) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
 
-		if resp.StatusCode != http.StatusOK {
-			defer resp.Body.Close()
-			return fmt.Errorf("unexpected status: %s for chainPath=%s with url=%s", resp.Status, chain.Path, url)
+	for _, chain := range chains {
+		if chain.ChainID != "" {
+			f.chainPaths[chain.ChainID] = chain.Path
 		}
+	}
+}
 
-		return nil
-	}); err != nil {
-		return nil, err
+// ResolveCounterpartyChain finds the chain on the other end of a channel from the client state of its connection
+// Returns the chain path from the chain directory if the chain ID is in it, otherwise the chain ID itself
+func (f *Fetcher) ResolveCounterpartyChain(ctx context.Context, chain Chain, ch Channel) (string, error) {
+	if len(ch.ConnectionHops) == 0 {
+		return "", fmt.Errorf("channel %s has no connection", ch.ChannelID)
 	}
+	connectionID := ch.ConnectionHops[0]
 
-	defer resp.Body.Close()
+	key := chain.Path + "/" + connectionID
+	f.mu.Lock()
+	chainID, ok := f.counterpartyChainIDs[key]
+	f.mu.Unlock()
 
-	bodyBytes, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("error reading response body: %w", err)
+	if !ok {
+		baseUrl := fmt.Sprintf("https://rest.cosmos.directory/%s", chain.Path)
+		if chain.baseUrl != "" {
+			baseUrl = chain.baseUrl
+		}
+
+		url := fmt.Sprintf("%s/ibc/core/connection/v1/connections/%s/client_state", baseUrl, connectionID)
+
+		var clientState ConnectionClientStateResponse
+		if err := f.client.GetJSON(ctx, url, &clientState); err != nil {
+			return "", fmt.Errorf("chainPath=%s: %w", chain.Path, err)
+		}
+
+		chainID = clientState.IdentifiedClientState.ClientState.ChainID
+		if chainID == "" {
+			return "", fmt.Errorf("client %s of connection %s has no chain ID", clientState.IdentifiedClientState.ClientID, connectionID)
+		}
+
+		f.mu.Lock()
+		f.counterpartyChainIDs[key] = chainID
+		f.mu.Unlock()
 	}
 
-	var channels ChannelResponse
-	if err := json.Unmarshal(bodyBytes, &channels); err != nil {
-		return nil, fmt.Errorf("JSON unmarshal error: %w", err)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if path, ok := f.chainPaths[chainID]; ok {
+		return path, nil
 	}
 
-	fmt.Printf("Fetched %d channels for chain %s\n", len(channels.Channels), chain.Path)
-	time.Sleep(500 * time.Millisecond) // Be nice to the server
+	return chainID, nil
+}
 
-	return &channels, nil
+// parseChainList parses a comma separated list of chain paths
+func parseChainList(list string) map[string]bool {
+	chains := make(map[string]bool)
+	for _, chain := range strings.Split(list, ",") {
+		if chain = strings.TrimSpace(chain); chain != "" {
+			chains[chain] = true
+		}
+	}
+
+	return chains
 }
 
-func retryWithBackoff(retries int, f func() error) error {
-	for i := 0; i < retries; i++ {
-		if err := f(); err != nil {
-			log.Printf("Error: %v. Retrying in %d seconds...", err, i*2)
-			time.Sleep(time.Duration(i*5) * time.Second)
-		} else {
-			return nil
+// filterChains drops the chains in skip, and if only isn't empty, every chain not in it
+func filterChains(chains []Chain, skip, only map[string]bool) []Chain {
+	var filtered []Chain
+	for _, chain := range chains {
+		if skip[chain.Path] {
+			fmt.Println("Skipping chain on the skip list:", chain.Path)
+			continue
+		}
+		if len(only) > 0 && !only[chain.Path] {
+			continue
 		}
+		filtered = append(filtered, chain)
 	}
-	return fmt.Errorf("retries exhausted")
+
+	if len(only) > 0 {
+		fmt.Printf("Only processing %d of %d chains\n", len(filtered), len(chains))
+	}
+
+	return filtered
 }
@@ -0,0 +1,247 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestClassifyVersion(t *testing.T) {
+	tests := []struct {
+		name           string
+		raw            string
+		wantApp        string
+		wantFee        string
+		wantMiddleware string
+	}{
+		{
+			name:    "ics20-1",
+			raw:     "ics20-1",
+			wantApp: "ics20-1",
+		},
+		{
+			name:    "ics20-2",
+			raw:     "ics20-2",
+			wantApp: "ics20-2",
+		},
+		{
+			name:    "surrounding whitespace",
+			raw:     " ics20-1\n",
+			wantApp: "ics20-1",
+		},
+		{
+			name:           "fee wrapped transfer",
+			raw:            `{"fee_version":"ics29-1","app_version":"ics20-1"}`,
+			wantApp:        "ics20-1",
+			wantFee:        "ics29-1",
+			wantMiddleware: "fee",
+		},
+		{
+			name:    "ics27 metadata",
+			raw:     `{"version":"ics27-1","controller_connection_id":"connection-0","host_connection_id":"connection-1","address":"","encoding":"proto3","tx_type":"sdk_multi_msg"}`,
+			wantApp: "ics27-1",
+		},
+		{
+			name:           "fee wrapped ics27 metadata",
+			raw:            `{"fee_version":"ics29-1","app_version":"{\"version\":\"ics27-1\",\"encoding\":\"proto3\",\"tx_type\":\"sdk_multi_msg\"}"}`,
+			wantApp:        "ics27-1",
+			wantFee:        "ics29-1",
+			wantMiddleware: "fee",
+		},
+		{
+			name:    "app version without fee",
+			raw:     `{"app_version":"ics20-1"}`,
+			wantApp: "ics20-1",
+		},
+		{
+			name:    "unknown plain version",
+			raw:     "my-custom-app-7",
+			wantApp: "my-custom-app-7",
+		},
+		{
+			name:    "unknown JSON version",
+			raw:     `{"foo":"bar"}`,
+			wantApp: `{"foo":"bar"}`,
+		},
+		{
+			name:    "invalid JSON",
+			raw:     `{"fee_version":`,
+			wantApp: `{"fee_version":`,
+		},
+		{
+			name: "empty",
+			raw:  "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app, fee, middleware := ClassifyVersion(tt.raw)
+			if app != tt.wantApp || fee != tt.wantFee || middleware != tt.wantMiddleware {
+				t.Errorf("ClassifyVersion(%q) = (%q, %q, %q), want (%q, %q, %q)",
+					tt.raw, app, fee, middleware, tt.wantApp, tt.wantFee, tt.wantMiddleware)
+			}
+		})
+	}
+}
+
+func TestCSVWriterCommentsAreSkipped(t *testing.T) {
+	file, err := os.Create(filepath.Join(t.TempDir(), "channels.csv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	w := newCSVWriter(file)
+	rows := []channelRow{
+		{ChainPath: "osmosis", Channel: Channel{ChannelID: "channel-0", State: "STATE_OPEN"}, Version: "ics20-1"},
+		{ChainPath: "cosmoshub", Channel: Channel{ChannelID: "channel-141", State: "STATE_OPEN"}, Version: "ics20-1"},
+	}
+
+	if err := w.WriteHeader(); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.WriteChannel(rows[0]); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.WriteError("Error fetching channels for juno: unexpected status: 503\nwith url=https://example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.WriteChannel(rows[1]); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.WritePartial("partial run, interrupted"); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	content, err := os.ReadFile(file.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(content), "# Error fetching channels for juno: unexpected status: 503 with url=https://example.com\n") {
+		t.Errorf("error comment missing from output:\n%s", content)
+	}
+
+	records, _, err := readChannelVersionsCSV(strings.NewReader(string(content)))
+	if err != nil {
+		t.Fatalf("readChannelVersionsCSV() error = %v", err)
+	}
+	if len(records) != len(rows) {
+		t.Errorf("read %d records, want %d:\n%s", len(records), len(rows), content)
+	}
+}
+
+func TestPlainWriterKeepsLegacyColumns(t *testing.T) {
+	file, err := os.Create(filepath.Join(t.TempDir(), "channels.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	w := newPlainWriter(file)
+	row := channelRow{
+		ChainPath: "osmosis",
+		Channel: Channel{
+			ChannelID:      "channel-0",
+			State:          "STATE_OPEN",
+			PortID:         "transfer",
+			ConnectionHops: []string{"connection-1"},
+		},
+		Version:           "ics20-1",
+		FeeVersion:        "ics29-1",
+		Middleware:        "fee",
+		CounterpartyChain: "cosmoshub",
+	}
+	if err := w.WriteChannel(row); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	content, err := os.ReadFile(file.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "osmosis, channel-0, STATE_OPEN, ics20-1, ics29-1\n"; string(content) != want {
+		t.Errorf("plain output = %q, want %q", content, want)
+	}
+}
+
+func TestDiffChannelVersionsSkipsFailedChains(t *testing.T) {
+	writers := map[string]func(file *os.File) outputWriter{
+		"channels.csv": func(file *os.File) outputWriter { return newCSVWriter(file) },
+		"channels.txt": func(file *os.File) outputWriter { return newPlainWriter(file) },
+	}
+
+	for name, newWriter := range writers {
+		t.Run(name, func(t *testing.T) {
+			writeRun := func(dir string, rows []channelRow, errorMsgs []string) string {
+				t.Helper()
+
+				file, err := os.Create(filepath.Join(dir, name))
+				if err != nil {
+					t.Fatal(err)
+				}
+				defer file.Close()
+
+				w := newWriter(file)
+				if err := w.WriteHeader(); err != nil {
+					t.Fatal(err)
+				}
+				for _, row := range rows {
+					if err := w.WriteChannel(row); err != nil {
+						t.Fatal(err)
+					}
+				}
+				for _, msg := range errorMsgs {
+					if err := w.WriteError(msg); err != nil {
+						t.Fatal(err)
+					}
+				}
+				if err := w.Flush(); err != nil {
+					t.Fatal(err)
+				}
+				return file.Name()
+			}
+
+			row := func(chainPath, channelID, version string) channelRow {
+				return channelRow{ChainPath: chainPath, Channel: Channel{ChannelID: channelID, State: "STATE_OPEN"}, Version: version}
+			}
+
+			oldFile := writeRun(t.TempDir(), []channelRow{
+				row("osmosis", "channel-0", "ics20-1"),
+				row("osmosis", "channel-1", "ics20-1"),
+				row("juno", "channel-0", "ics20-1"),
+				row("juno", "channel-1", "ics20-1"),
+			}, nil)
+			// juno failed halfway through, osmosis really lost a channel
+			newFile := writeRun(t.TempDir(), []channelRow{
+				row("osmosis", "channel-0", "ics20-2"),
+				row("juno", "channel-0", "ics20-1"),
+			}, []string{"Failed to fetch channels for chain juno: unexpected status: 503 Service Unavailable"})
+
+			diff, err := DiffChannelVersions(oldFile, newFile)
+			if err != nil {
+				t.Fatalf("DiffChannelVersions() error = %v", err)
+			}
+
+			if len(diff.Removed) != 1 || diff.Removed[0].key() != "osmosis/channel-1" {
+				t.Errorf("Removed = %v, want only osmosis/channel-1", diff.Removed)
+			}
+			if len(diff.Failed) != 1 || diff.Failed[0] != "juno" {
+				t.Errorf("Failed = %v, want [juno]", diff.Failed)
+			}
+			if len(diff.Changed) != 1 || diff.Changed[0].New.key() != "osmosis/channel-0" {
+				t.Errorf("Changed = %v, want only osmosis/channel-0", diff.Changed)
+			}
+			if len(diff.Added) != 0 {
+				t.Errorf("Added = %v, want none", diff.Added)
+			}
+		})
+	}
+}
@@ -1,21 +1,141 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"flag"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
 	"time"
+
+	"github.com/gjermundgaraba/scripts/httpx"
 )
 
+// Fetcher fetches chains and their IBC connections and channels, sending all requests through the same http.Client
+// It is shared by all workers so requests to the same chain are spaced out
+type Fetcher struct {
+	client *httpx.Client
+
+	// ConnectionWorkers is how many connections of a chain have their channels fetched concurrently
+	ConnectionWorkers int
+}
+
+// NewFetcher creates a fetcher spacing out requests to the same chain by at least interval, and requests to the same
+// host by at least hostInterval, since every chain is reached through the same rest.cosmos.directory proxy
+func NewFetcher(httpClient *http.Client, interval, hostInterval time.Duration) *Fetcher {
+	client := httpx.NewClient(httpClient, interval, 5)
+	client.KeyFunc = httpx.FirstPathSegmentKey
+	client.SetSharedHostInterval(hostInterval)
+
+	return &Fetcher{
+		client:            client,
+		ConnectionWorkers: 4,
+	}
+}
+
+// ChainUsage accumulates the matching connections and channels found on a chain
+type ChainUsage struct {
+	Chain       string `json:"chain"`
+	Channels    int    `json:"localhost_channels"`
+	Connections int    `json:"localhost_connections"`
+
+	// TotalConnections is the number of connections of any client type on the chain
+	TotalConnections int `json:"total_connections"`
+
+	// ConnectionUsages is only written in the verbose output
+	ConnectionUsages []ConnectionUsage `json:"connections,omitempty"`
+
+	channelCounts map[string]int // Channel count per client ID, used by the text output
+	clientTypes   map[string]int // Connection count per client type (e.g. 07-tendermint) across all connections
+}
+
+// ConnectionUsage is the number of channels on a single matching connection
+type ConnectionUsage struct {
+	ConnectionID string `json:"connection_id"`
+	ClientID     string `json:"client_id"`
+	Channels     int    `json:"channels"`
+	// ReportedChannels is the total the chain reported while paging, -1 if it didn't report one
+	// It differs from Channels if channels were opened while paging or the node miscounts
+	ReportedChannels int `json:"reported_channels"`
+}
+
 type Chain struct {
 	Path string `json:"path"`
 
-	baseUrl string // Optional and only used when fetching for a specific chain
+	BaseURL string `json:"base_url,omitempty"` // Optional and only used when fetching for a specific chain or from a config file
+
+	// RestPath overrides Path in rest.cosmos.directory URLs, for chains whose REST proxy is served under a different
+	// name than their directory listing, which otherwise fails with 404s. Only set from a config file, e.g.
+	// {"path": "mychain", "rest_path": "mychain-mainnet"}. Which chains need it changes as the directory is updated,
+	// so check the 404s in the errors file rather than relying on a fixed list.
+	RestPath string `json:"rest_path,omitempty"`
 }
 
+// restBaseURL returns the base URL of the chain's REST API: the BaseURL override, or the rest.cosmos.directory proxy
+// under RestPath, falling back to Path
+func (c Chain) restBaseURL() string {
+	if c.BaseURL != "" {
+		return c.BaseURL
+	}
+
+	restPath := c.Path
+	if c.RestPath != "" {
+		restPath = c.RestPath
+	}
+	return fmt.Sprintf("https://rest.cosmos.directory/%s", restPath)
+}
+
+// dedupeChains merges chains listed more than once under the same path, e.g. directory aliases, keeping the first
+// position. An entry without a base URL or REST path takes the one of a duplicate, conflicting ones keep the first.
+func dedupeChains(chains []Chain) []Chain {
+	deduped := make([]Chain, 0, len(chains))
+	indexes := make(map[string]int, len(chains))
+	for _, chain := range chains {
+		i, found := indexes[chain.Path]
+		if !found {
+			indexes[chain.Path] = len(deduped)
+			deduped = append(deduped, chain)
+			continue
+		}
+
+		switch existing := deduped[i]; {
+		case existing.BaseURL == "":
+			deduped[i].BaseURL = chain.BaseURL
+		case chain.BaseURL != "" && chain.BaseURL != existing.BaseURL:
+			log.Printf("Chain %s is listed with base URLs %s and %s, using the first", chain.Path, existing.BaseURL, chain.BaseURL)
+		}
+		if deduped[i].RestPath == "" {
+			deduped[i].RestPath = chain.RestPath
+		}
+	}
+
+	if removed := len(chains) - len(deduped); removed > 0 {
+		fmt.Printf("Merged %d duplicate chain entries\n", removed)
+	}
+
+	return deduped
+}
+
+const (
+	chainDirectoryURL = "https://chains.cosmos.directory"
+	// The directory lists every chain with its full metadata, so it's big, but anything past this is not the directory
+	chainDirectoryMaxBytes = 64 << 20
+	// Covers all retries, including the backoff between them
+	chainDirectoryTimeout = 2 * time.Minute
+	// Shared by both fetch tools, so running one right after the other only downloads the directory once
+	chainDirectoryCacheFile = "out/cache/chain_directory.json"
+	chainDirectoryCacheTTL  = time.Hour
+)
+
 // ChainDirectoryResponse represents the structure of the response from https://chains.cosmos.directory
 type ChainDirectoryResponse struct {
 	Chains []Chain `json:"chains"`
@@ -65,214 +185,661 @@ type PaginatedResponse[T any] interface {
 }
 
 func main() {
+	limitChains := flag.Int("limit-chains", 0, "Only process the first N chains from the chain directory, 0 processes all of them")
+	workers := flag.Int("workers", 4, "Number of chains to process concurrently")
+	connectionWorkers := flag.Int("connection-workers", 4, "Number of connections per chain to fetch channels for concurrently")
+	interval := flag.Duration("interval", 500*time.Millisecond, "Minimum time between requests to the same chain")
+	hostInterval := flag.Duration("host-interval", 100*time.Millisecond, "Minimum time between requests to the same host across all chains, e.g. rest.cosmos.directory")
+	maxFailures := flag.Int("max-failures", 10, "Skip a chain's endpoint for the rest of the run after this many consecutive failed requests (0 never skips)")
+	clientPrefix := flag.String("client-prefix", "09-localhost", "Client ID prefix to search for, e.g. 07-tendermint, 06-solomachine or 08-wasm")
+	configFile := flag.String("config", "", "JSON file with a list of chains to fetch, e.g. [{\"path\": \"osmosis\", \"base_url\": \"https://...\"}], rest_path overrides the rest.cosmos.directory path")
+	format := flag.String("format", "text", "Output format: text, json, or jsonl (one JSON object per chain, written as each chain completes)")
+	clientBreakdown := flag.Bool("client-breakdown", false, "Also write the number of connections per client type for every chain to out/client_type_breakdown.txt")
+	verbose := flag.Bool("verbose", false, "List each matching connection and its channel count under every chain")
+	network := flag.String("network", "tcp", "Network to dial nodes over: tcp (IPv4 or IPv6), tcp4 (IPv4 only) or tcp6 (IPv6 only)")
+	dnsServer := flag.String("dns-server", "", "host:port of a DNS server to resolve nodes with instead of the system resolver, e.g. an internal resolver")
+	insecure := flag.Bool("insecure", false, "Skip TLS certificate verification, for local nodes with self-signed certificates (unsafe)")
+	caCert := flag.String("ca-cert", "", "PEM file with extra CA certificates to trust, e.g. the CA of an internal testnet")
+	refreshChains := flag.Bool("refresh-chains", false, "Download the chain directory again even if the cached copy is less than an hour old")
+	flag.Parse()
+	args := flag.Args()
+
+	// Cancel the context on Ctrl-C so we can still write what we have collected so far
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	httpClient, err := httpx.NewHTTPClient(httpx.TransportConfig{
+		Network:    *network,
+		DNSServer:  *dnsServer,
+		CACertFile: *caCert,
+		Insecure:   *insecure,
+	})
+	if err != nil {
+		log.Fatalf("Invalid transport config: %v", err)
+	}
+	if *insecure {
+		log.Println("WARNING: -insecure is set, TLS certificates are not verified and responses could come from anyone on the network")
+	}
+	fetcher := NewFetcher(httpClient, *interval, *hostInterval)
+	fetcher.client.SetMaxFailures(*maxFailures)
+
+	if *workers < 1 {
+		log.Fatalf("Invalid number of workers: %d", *workers)
+	}
+	if *connectionWorkers < 1 {
+		log.Fatalf("Invalid number of connection workers: %d", *connectionWorkers)
+	}
+	fetcher.ConnectionWorkers = *connectionWorkers
+
+	var extension string
+	switch *format {
+	case "text":
+		extension = "txt"
+	case "json":
+		extension = "json"
+	case "jsonl":
+		extension = "jsonl"
+	default:
+		log.Fatalf("Invalid output format: %s (must be text, json or jsonl)", *format)
+	}
+
 	// 1. Fetch the list of chains (or use the provided chain argument)
 	var chains []Chain
-	if len(os.Args) > 1 {
-		chainPath := os.Args[1]
+	if *configFile != "" {
+		var err error
+		chains, err = loadChainsConfig(*configFile)
+		if err != nil {
+			log.Fatalf("Failed to load chains config: %v", err)
+		}
+
+		chains = dedupeChains(chains)
+		fmt.Printf("Loaded %d chains from config file %s\n", len(chains), *configFile)
+	} else if len(args) > 0 {
+		chainPath := args[0]
 
 		fmt.Println("Chain argument provided, will only fetch connections for chain:", chainPath)
 
 		baseUrl := ""
-		if len(os.Args) > 2 {
-			baseUrl = os.Args[2]
+		if len(args) > 1 {
+			baseUrl = args[1]
 		}
 
 		fmt.Println("Base URL override provided:", baseUrl)
 
-		chains = []Chain{{Path: chainPath, baseUrl: baseUrl}}
+		chains = []Chain{{Path: chainPath, BaseURL: baseUrl}}
 	} else {
 		var err error
-		chains, err = fetchChains()
+		chains, err = fetcher.fetchChains(ctx, *refreshChains)
 		if err != nil {
 			log.Fatalf("Failed to fetch chains: %v", err)
 		}
+
+		chains = dedupeChains(chains)
+
+		if *limitChains > 0 && *limitChains < len(chains) {
+			fmt.Printf("Limiting to the first %d of %d chains\n", *limitChains, len(chains))
+			chains = chains[:*limitChains]
+		}
 	}
 
-	// Create/Truncate the output file
-	fileName := "out/localhost_chain_usage.txt"
+	// Create/Truncate the output file, named after the client type (e.g. 09-localhost -> localhost)
+	clientType := *clientPrefix
+	if idx := strings.Index(clientType, "-"); idx != -1 {
+		clientType = clientType[idx+1:]
+	}
+	fileName := fmt.Sprintf("out/%s_chain_usage.%s", clientType, extension)
 	file, err := os.Create(fileName)
 	if err != nil {
 		log.Fatalf("Failed to create output file: %v", err)
 	}
 	defer file.Close()
 
-	// 2. For each chain, fetch all IBC connections in pages of 50
-	for _, chain := range chains {
-		connections, err := fetchPaginated[Connection](func(offset int) (PaginatedResponse[Connection], error) {
-			return fetchIBCConnections(chain, offset, 50)
-		})
-		if err != nil {
-			fmt.Printf("Failed to fetch connections for chain %s: %v\n", chain.Path, err)
-			continue
+	// Chains with matching connections, written out once all chains are processed
+	var usages []ChainUsage
+	// Every chain that was fully queried, for the client type breakdown
+	var allUsages []ChainUsage
+
+	// Chains that couldn't be fully queried, so they can be told apart from chains without matching clients
+	var skippedChains []string
+
+	// JSON lines are streamed instead, so a crash still leaves every completed chain in the file
+	var streamUsage func(usage ChainUsage) error
+	if *format == "jsonl" {
+		encoder := json.NewEncoder(file)
+		streamUsage = func(usage ChainUsage) error {
+			if !*verbose {
+				usage.ConnectionUsages = nil
+			}
+			// The encoder writes straight to the file, so every line is flushed as soon as it's written
+			return encoder.Encode(usage)
 		}
+	}
 
-		numLocalhost := 0
-		hasLocalhost := false
-		for _, conn := range connections {
-			if conn.ClientID == "09-localhost" {
-				hasLocalhost = true
-				channels, err := fetchPaginated[struct{}](func(offset int) (PaginatedResponse[struct{}], error) {
-					return fetchIBCChannelsForConnection(chain, conn.ID, offset, 50)
-				})
+	// 2. Process chains concurrently, each worker handles a whole chain
+	var mu sync.Mutex
+	jobs := make(chan Chain)
+	var wg sync.WaitGroup
+	for range *workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for chain := range jobs {
+				usage, err := fetcher.processChain(ctx, chain, *clientPrefix)
+				if err != nil && ctx.Err() != nil {
+					// Interrupted, the chain is neither processed nor skipped
+					continue
+				}
 				if err != nil {
-					fmt.Printf("Failed to fetch channels for connection %s on chain %s: %v\n", conn.ID, chain.Path, err)
+					fmt.Printf("Failed to process chain %s: %v\n", chain.Path, err)
+					mu.Lock()
+					skippedChains = append(skippedChains, fmt.Sprintf("%s, %v", chain.Path, err))
+					mu.Unlock()
 					continue
 				}
-				numLocalhost += len(channels)
+
+				mu.Lock()
+				allUsages = append(allUsages, usage)
+				// Only chains with matching clients end up in the output
+				if usage.Connections > 0 && streamUsage != nil {
+					// Written under the lock so lines from different workers never interleave
+					if err := streamUsage(usage); err != nil {
+						log.Fatalf("Failed to write output file: %v", err)
+					}
+				} else if usage.Connections > 0 {
+					usages = append(usages, usage)
+				}
+				mu.Unlock()
 			}
+		}()
+	}
+
+dispatch:
+	for _, chain := range chains {
+		select {
+		case jobs <- chain:
+		case <-ctx.Done():
+			break dispatch
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	var partialMsg string
+	if ctx.Err() != nil {
+		partialMsg = fmt.Sprintf("partial run: interrupted with %d of %d chains complete and %d skipped", len(allUsages), len(chains), len(skippedChains))
+		log.Println(partialMsg)
+	}
+
+	// Most localhost channels first, so the chains that matter most are at the top of the file
+	sort.Slice(usages, func(i, j int) bool {
+		if usages[i].Channels != usages[j].Channels {
+			return usages[i].Channels > usages[j].Channels
+		}
+		return usages[i].Chain < usages[j].Chain
+	})
+	if !*verbose {
+		for i := range usages {
+			usages[i].ConnectionUsages = nil
+		}
+	}
+
+	switch {
+	case *format == "jsonl":
+		// Already written as the chains completed
+	case *format == "json":
+		err = writeUsageJSON(file, usages)
+	case *verbose:
+		err = writeUsageTree(file, usages)
+	default:
+		err = writeUsageText(file, usages)
+	}
+	// The JSON output stays a plain array and JSON lines stay valid JSON, the marker goes to the errors file instead
+	if err == nil && partialMsg != "" && *format == "text" {
+		_, err = fmt.Fprintf(file, "# %s\n", partialMsg)
+	}
+	if err != nil {
+		log.Fatalf("Failed to write output file: %v", err)
+	}
+
+	if partialMsg != "" {
+		fmt.Printf("Interrupted! Wrote partial results for chains with %s clients in: %s\n", *clientPrefix, fileName)
+	} else {
+		fmt.Printf("Done! Wrote chains with %s clients in: %s\n", *clientPrefix, fileName)
+	}
+
+	if *clientBreakdown {
+		breakdownFileName := "out/client_type_breakdown.txt"
+		if err := writeClientTypeBreakdown(breakdownFileName, allUsages); err != nil {
+			log.Fatalf("Failed to write client type breakdown: %v", err)
 		}
+		fmt.Println("Wrote connections per client type in:", breakdownFileName)
 
-		if hasLocalhost {
-			_, _ = file.WriteString(fmt.Sprintf("%s, %d\n", chain.Path, numLocalhost))
+		totals := make(map[string]int)
+		for _, usage := range allUsages {
+			for clientType, count := range usage.clientTypes {
+				totals[clientType] += count
+			}
 		}
+		printClientTypeTotals(totals, len(allUsages))
 	}
 
-	fmt.Println("Done! Wrote chains with localhost in:", fileName)
+	errorsFileName := fmt.Sprintf("out/%s_errors.txt", clientType)
+	skippedCount := len(skippedChains)
+	if partialMsg != "" {
+		// Sorts first, above the skipped chains
+		skippedChains = append(skippedChains, "# "+partialMsg)
+	}
+	if err := writeSkippedChains(errorsFileName, skippedChains); err != nil {
+		log.Fatalf("Failed to write skipped chains: %v", err)
+	}
+	fmt.Printf("Skipped %d chains that couldn't be queried, see: %s\n", skippedCount, errorsFileName)
+
+	if skippedHosts := fetcher.client.SkippedHosts(); len(skippedHosts) > 0 {
+		fmt.Printf("Skipped %d hosts for the rest of the run after %d consecutive failures: %s\n", len(skippedHosts), *maxFailures, strings.Join(skippedHosts, ", "))
+	}
 }
 
-func fetchPaginated[T any](f func(int) (PaginatedResponse[T], error)) ([]T, error) {
-	offset := 0
-	var all []T
+// writeUsageText writes the legacy "chain, clientID, count" line per matching client
+func writeUsageText(w io.Writer, usages []ChainUsage) error {
+	for _, usage := range usages {
+		clientIDs := make([]string, 0, len(usage.channelCounts))
+		for clientID := range usage.channelCounts {
+			clientIDs = append(clientIDs, clientID)
+		}
+		sort.Strings(clientIDs)
 
-	for {
-		resp, err := f(offset)
-		if err != nil {
-			return nil, err
+		for _, clientID := range clientIDs {
+			if _, err := fmt.Fprintf(w, "%s, %s, %d\n", usage.Chain, clientID, usage.channelCounts[clientID]); err != nil {
+				return err
+			}
 		}
+	}
 
-		all = append(all, resp.GetItems()...)
+	return nil
+}
 
-		if resp.GetPagination().NextKey == nil {
-			break
+// writeUsageTree writes every chain followed by its matching connections, indented, e.g.
+//
+//	osmosis: 2 of 150 connections, 3 channels
+//	  connection-0 (09-localhost): 2 channels
+//	  connection-7 (09-localhost): 1 channels
+func writeUsageTree(w io.Writer, usages []ChainUsage) error {
+	for _, usage := range usages {
+		if _, err := fmt.Fprintf(w, "%s: %d of %d connections, %d channels\n", usage.Chain, usage.Connections, usage.TotalConnections, usage.Channels); err != nil {
+			return err
 		}
 
-		offset += len(resp.GetItems())
+		for _, conn := range usage.ConnectionUsages {
+			reported := ""
+			if conn.ReportedChannels >= 0 && conn.ReportedChannels != conn.Channels {
+				reported = fmt.Sprintf(" (chain reported %d)", conn.ReportedChannels)
+			}
+			if _, err := fmt.Fprintf(w, "  %s (%s): %d channels%s\n", conn.ConnectionID, conn.ClientID, conn.Channels, reported); err != nil {
+				return err
+			}
+		}
 	}
 
-	return all, nil
+	return nil
 }
 
-// fetchChains fetches the list of chains from https://chains.cosmos.directory
-func fetchChains() ([]Chain, error) {
-	resp, err := http.Get("https://chains.cosmos.directory")
-	if err != nil {
-		return nil, fmt.Errorf("GET error: %w", err)
+// writeUsageJSON writes the chain usages as an indented JSON array
+func writeUsageJSON(w io.Writer, usages []ChainUsage) error {
+	// Always write an array, even when no chain has matching clients
+	if usages == nil {
+		usages = []ChainUsage{}
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("unexpected status: %s", resp.Status)
+	bz, err := json.MarshalIndent(usages, "", "  ")
+	if err != nil {
+		return err
 	}
 
-	bodyBytes, err := io.ReadAll(resp.Body)
+	_, err = w.Write(append(bz, '\n'))
+	return err
+}
+
+// writeClientTypeBreakdown writes one "chain, clientType, connections" line per client type on every chain
+func writeClientTypeBreakdown(fileName string, usages []ChainUsage) error {
+	sort.Slice(usages, func(i, j int) bool { return usages[i].Chain < usages[j].Chain })
+
+	file, err := os.Create(fileName)
 	if err != nil {
-		return nil, fmt.Errorf("error reading response body: %w", err)
+		return err
 	}
+	defer file.Close()
 
-	var chainResp ChainDirectoryResponse
-	if err := json.Unmarshal(bodyBytes, &chainResp); err != nil {
-		return nil, fmt.Errorf("JSON unmarshal error: %w", err)
+	for _, usage := range usages {
+		clientTypes := make([]string, 0, len(usage.clientTypes))
+		for clientType := range usage.clientTypes {
+			clientTypes = append(clientTypes, clientType)
+		}
+		sort.Strings(clientTypes)
+
+		for _, clientType := range clientTypes {
+			if _, err := fmt.Fprintf(file, "%s, %s, %d\n", usage.Chain, clientType, usage.clientTypes[clientType]); err != nil {
+				return err
+			}
+		}
 	}
 
-	return chainResp.Chains, nil
+	return nil
 }
 
-func fetchIBCConnections(chain Chain, offset, limit int) (*ConnectionResponse, error) {
-	baseUrl := fmt.Sprintf("https://rest.cosmos.directory/%s", chain.Path)
-	if chain.baseUrl != "" {
-		baseUrl = chain.baseUrl
+// printClientTypeTotals prints the number of connections per client type across all chains, most used first
+func printClientTypeTotals(totals map[string]int, chains int) {
+	clientTypes := make([]string, 0, len(totals))
+	for clientType := range totals {
+		clientTypes = append(clientTypes, clientType)
 	}
+	sort.Slice(clientTypes, func(i, j int) bool {
+		if totals[clientTypes[i]] != totals[clientTypes[j]] {
+			return totals[clientTypes[i]] > totals[clientTypes[j]]
+		}
+		return clientTypes[i] < clientTypes[j]
+	})
 
-	url := fmt.Sprintf("%s/ibc/core/connection/v1/connections?pagination.limit=%d&pagination.offset=%d", baseUrl, limit, offset)
+	fmt.Printf("Connections per client type across %d chains:\n", chains)
+	for _, clientType := range clientTypes {
+		fmt.Printf("  %s: %d\n", clientType, totals[clientType])
+	}
+}
 
-	var resp *http.Response
-	var err error
-	if err := retryWithBackoff(5, func() error {
-		resp, err = http.Get(url)
-		if err != nil {
-			return fmt.Errorf("GET error: %w", err)
-		}
+// clientTypeOf returns the client type of a client ID, e.g. 07-tendermint for 07-tendermint-42
+func clientTypeOf(clientID string) string {
+	idx := strings.LastIndex(clientID, "-")
+	if idx == -1 {
+		return clientID
+	}
 
-		if resp.StatusCode != http.StatusOK {
-			defer resp.Body.Close()
-			return fmt.Errorf("unexpected status: %s for chainPath=%s with url=%s", resp.Status, chain.Path, url)
+	if _, err := strconv.Atoi(clientID[idx+1:]); err != nil {
+		// Not a client counter suffix, e.g. 09-localhost which has no counter
+		return clientID
+	}
+
+	return clientID[:idx]
+}
+
+// writeSkippedChains writes one "chain, error" line per chain that couldn't be queried
+func writeSkippedChains(fileName string, skippedChains []string) error {
+	sort.Strings(skippedChains)
+
+	file, err := os.Create(fileName)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	for _, line := range skippedChains {
+		if _, err := file.WriteString(line + "\n"); err != nil {
+			return err
 		}
+	}
 
-		return nil
-	}); err != nil {
-		return nil, err
+	return nil
+}
+
+// processChain counts the chain's connections whose client ID has the given prefix and the channels on them
+// The chain fails if the channels of any of those connections couldn't be fetched, since its counts would be too low
+func (f *Fetcher) processChain(ctx context.Context, chain Chain, clientPrefix string) (ChainUsage, error) {
+	allConnections, err := f.fetchAllConnections(ctx, chain)
+	if err != nil {
+		return ChainUsage{}, err
+	}
+	connections := filterConnectionsByClientPrefix(allConnections, clientPrefix)
+
+	usage := ChainUsage{
+		Chain:            chain.Path,
+		Connections:      len(connections),
+		TotalConnections: len(allConnections),
+		channelCounts:    make(map[string]int),
+		clientTypes:      countClientTypes(allConnections),
 	}
 
-	defer resp.Body.Close()
+	// Connections are independent, so fetch their channels concurrently. Requests still go through
+	// the shared client, which keeps them spaced out per chain
+	var mu sync.Mutex
+	var failed int
+	var firstErr error
+	jobs := make(chan Connection)
+	var wg sync.WaitGroup
+	for range min(f.ConnectionWorkers, len(connections)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for conn := range jobs {
+				connUsage, err := f.fetchConnectionChannels(ctx, chain, conn)
+				if err != nil {
+					if ctx.Err() == nil {
+						fmt.Printf("Failed to fetch channels for connection %s on chain %s: %v\n", conn.ID, chain.Path, err)
+					}
+					mu.Lock()
+					failed++
+					if firstErr == nil {
+						firstErr = err
+					}
+					mu.Unlock()
+					continue
+				}
 
-	bodyBytes, err := io.ReadAll(resp.Body)
+				mu.Lock()
+				usage.channelCounts[conn.ClientID] += connUsage.Channels
+				usage.Channels += connUsage.Channels
+				usage.ConnectionUsages = append(usage.ConnectionUsages, connUsage)
+				mu.Unlock()
+			}
+		}()
+	}
+
+	for _, conn := range connections {
+		jobs <- conn
+	}
+	close(jobs)
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		return ChainUsage{}, err
+	}
+	if failed > 0 {
+		return ChainUsage{}, fmt.Errorf("failed to fetch channels for %d of %d connections: %w", failed, len(connections), firstErr)
+	}
+
+	sort.Slice(usage.ConnectionUsages, func(i, j int) bool {
+		return usage.ConnectionUsages[i].ConnectionID < usage.ConnectionUsages[j].ConnectionID
+	})
+
+	return usage, nil
+}
+
+// fetchConnectionChannels counts the channels on a single connection, along with the total the chain reported
+// A connection without channels costs a single request
+func (f *Fetcher) fetchConnectionChannels(ctx context.Context, chain Chain, conn Connection) (ConnectionUsage, error) {
+	label := fmt.Sprintf("channels for connection %s on chain %s", conn.ID, chain.Path)
+	channels, reportedTotal, err := fetchPaginated[struct{}](label, func(offset int) (PaginatedResponse[struct{}], error) {
+		return f.fetchIBCChannelsForConnection(ctx, chain, conn.ID, offset, 50)
+	})
 	if err != nil {
-		return nil, fmt.Errorf("error reading response body: %w", err)
+		return ConnectionUsage{}, err
 	}
 
-	var connections ConnectionResponse
-	if err := json.Unmarshal(bodyBytes, &connections); err != nil {
-		return nil, fmt.Errorf("JSON unmarshal error: %w", err)
+	return ConnectionUsage{
+		ConnectionID:     conn.ID,
+		ClientID:         conn.ClientID,
+		Channels:         len(channels),
+		ReportedChannels: reportedTotal,
+	}, nil
+}
+
+// SummarizeConnections fetches all IBC connections for a chain and counts them per client type,
+// e.g. 07-tendermint, 08-wasm, 09-localhost and 06-solomachine
+func (f *Fetcher) SummarizeConnections(ctx context.Context, chain Chain) (map[string]int, error) {
+	connections, err := f.fetchAllConnections(ctx, chain)
+	if err != nil {
+		return nil, err
 	}
 
-	fmt.Printf("Fetched %d connections for chain %s\n", len(connections.Connections), chain.Path)
-	time.Sleep(500 * time.Millisecond) // Be nice to the server
+	return countClientTypes(connections), nil
+}
 
-	return &connections, nil
+// countClientTypes counts the connections per client type of their client ID
+func countClientTypes(connections []Connection) map[string]int {
+	clientTypes := make(map[string]int)
+	for _, conn := range connections {
+		clientTypes[clientTypeOf(conn.ClientID)]++
+	}
+
+	return clientTypes
 }
 
-func fetchIBCChannelsForConnection(chain Chain, connectionID string, offset, limit int) (*ChannelResponse, error) {
-	baseUrl := fmt.Sprintf("https://rest.cosmos.directory/%s", chain.Path)
-	if chain.baseUrl != "" {
-		baseUrl = chain.baseUrl
+// FindConnectionsByClientPrefix fetches all IBC connections for a chain in pages of 50
+// and returns the ones whose client ID starts with prefix, along with the total number of connections
+func (f *Fetcher) FindConnectionsByClientPrefix(ctx context.Context, chain Chain, prefix string) ([]Connection, int, error) {
+	connections, err := f.fetchAllConnections(ctx, chain)
+	if err != nil {
+		return nil, 0, err
 	}
 
-	url := fmt.Sprintf("%s/ibc/core/channel/v1/connections/%s/channels?pagination.limit=%d&pagination.offset=%d", baseUrl, connectionID, limit, offset)
+	return filterConnectionsByClientPrefix(connections, prefix), len(connections), nil
+}
+
+// fetchAllConnections fetches all IBC connections for a chain in pages of 50
+func (f *Fetcher) fetchAllConnections(ctx context.Context, chain Chain) ([]Connection, error) {
+	label := fmt.Sprintf("connections on chain %s", chain.Path)
+	connections, _, err := fetchPaginated[Connection](label, func(offset int) (PaginatedResponse[Connection], error) {
+		return f.fetchIBCConnections(ctx, chain, offset, 50)
+	})
+	return connections, err
+}
 
-	var resp *http.Response
-	var err error
-	if err := retryWithBackoff(5, func() error {
-		resp, err = http.Get(url)
+// filterConnectionsByClientPrefix returns the connections whose client ID starts with prefix
+func filterConnectionsByClientPrefix(connections []Connection, prefix string) []Connection {
+	var matched []Connection
+	for _, conn := range connections {
+		if strings.HasPrefix(conn.ClientID, prefix) {
+			matched = append(matched, conn)
+		}
+	}
+
+	return matched
+}
+
+// fetchPaginated fetches all pages and warns if the number of items doesn't match the total reported
+// with the first page, which means items were added or removed while paging. The label names the items in the warning
+// Along with the items it returns the reported total, or -1 if the chain didn't report a usable one
+func fetchPaginated[T any](label string, f func(int) (PaginatedResponse[T], error)) ([]T, int, error) {
+	offset := 0
+	var all []T
+	reportedTotal, hasTotal := 0, false
+
+	for {
+		resp, err := f(offset)
 		if err != nil {
-			return fmt.Errorf("GET error: %w", err)
+			return nil, 0, err
 		}
 
-		if resp.StatusCode != http.StatusOK {
-			defer resp.Body.Close()
-			return fmt.Errorf("unexpected status: %s for chainPath=%s with url=%s", resp.Status, chain.Path, url)
+		if offset == 0 {
+			reportedTotal, hasTotal = parseTotal(resp.GetPagination().Total)
 		}
 
-		return nil
-	}); err != nil {
-		return nil, err
+		all = append(all, resp.GetItems()...)
+
+		// An empty page is the end even if the node still sends a next_key, paging on would request the same offset
+		if resp.GetPagination().NextKey == nil || len(resp.GetItems()) == 0 {
+			break
+		}
+
+		offset += len(resp.GetItems())
+	}
+
+	// Nodes that ignore count_total report 0, which can't be told apart from drift when items were found
+	if !hasTotal || (reportedTotal == 0 && len(all) > 0) {
+		return all, -1, nil
+	}
+
+	if reportedTotal != len(all) {
+		fmt.Printf("Warning: fetched %d %s, but the chain reported a total of %d\n", len(all), label, reportedTotal)
+	}
+
+	return all, reportedTotal, nil
+}
+
+// parseTotal parses the pagination total, which is a string in the REST API
+// Returns false if it is empty or not a number
+func parseTotal(total string) (int, bool) {
+	n, err := strconv.Atoi(strings.TrimSpace(total))
+	if err != nil || n < 0 {
+		return 0, false
 	}
 
-	defer resp.Body.Close()
+	return n, true
+}
 
-	bodyBytes, err := io.ReadAll(resp.Body)
+// loadChainsConfig loads the list of chains, with optional REST endpoints, from a JSON file
+func loadChainsConfig(fileName string) ([]Chain, error) {
+	bz, err := os.ReadFile(fileName)
 	if err != nil {
-		return nil, fmt.Errorf("error reading response body: %w", err)
+		return nil, err
 	}
 
-	var channels ChannelResponse
-	if err := json.Unmarshal(bodyBytes, &channels); err != nil {
+	var chains []Chain
+	if err := json.Unmarshal(bz, &chains); err != nil {
 		return nil, fmt.Errorf("JSON unmarshal error: %w", err)
 	}
 
-	fmt.Printf("Fetched %d channels for connection %s on chain %s\n", len(channels.Channels), connectionID, chain.Path)
-	time.Sleep(500 * time.Millisecond) // Be nice to the server
+	for i, chain := range chains {
+		if chain.Path == "" {
+			return nil, fmt.Errorf("chain %d in %s is missing a path", i, fileName)
+		}
+	}
 
-	return &channels, nil
+	return chains, nil
 }
 
-func retryWithBackoff(retries int, f func() error) error {
-	for i := range retries {
-		if err := f(); err != nil {
-			log.Printf("Error: %v. Retrying in %d seconds...", err, i*2)
-			time.Sleep(time.Duration(i*5) * time.Second)
-		} else {
-			return nil
-		}
+// fetchChains fetches the list of chains from https://chains.cosmos.directory
+// The directory is served from the on-disk cache while it is fresh, unless refresh is set
+func (f *Fetcher) fetchChains(ctx context.Context, refresh bool) ([]Chain, error) {
+	ctx, cancel := context.WithTimeout(ctx, chainDirectoryTimeout)
+	defer cancel()
+
+	ttl := chainDirectoryCacheTTL
+	if refresh {
+		ttl = 0
+	}
+
+	var chainResp ChainDirectoryResponse
+	if err := f.client.GetJSONCached(ctx, chainDirectoryURL, &chainResp, chainDirectoryMaxBytes, chainDirectoryCacheFile, ttl); err != nil {
+		return nil, fmt.Errorf("failed to fetch the chain directory from %s: %w", chainDirectoryURL, err)
+	}
+
+	return chainResp.Chains, nil
+}
+
+func (f *Fetcher) fetchIBCConnections(ctx context.Context, chain Chain, offset, limit int) (*ConnectionResponse, error) {
+	baseUrl := chain.restBaseURL()
+
+	url := fmt.Sprintf("%s/ibc/core/connection/v1/connections?pagination.limit=%d&pagination.offset=%d&pagination.count_total=true", baseUrl, limit, offset)
+
+	var connections ConnectionResponse
+	if err := f.client.GetJSON(ctx, url, &connections); err != nil {
+		return nil, fmt.Errorf("chainPath=%s: %w", chain.Path, err)
 	}
-	return fmt.Errorf("retries exhausted")
+
+	fmt.Printf("Fetched %d connections for chain %s\n", len(connections.Connections), chain.Path)
+
+	return &connections, nil
+}
+
+func (f *Fetcher) fetchIBCChannelsForConnection(ctx context.Context, chain Chain, connectionID string, offset, limit int) (*ChannelResponse, error) {
+	baseUrl := chain.restBaseURL()
+
+	url := fmt.Sprintf("%s/ibc/core/channel/v1/connections/%s/channels?pagination.limit=%d&pagination.offset=%d&pagination.count_total=true", baseUrl, connectionID, limit, offset)
+
+	var channels ChannelResponse
+	if err := f.client.GetJSON(ctx, url, &channels); err != nil {
+		return nil, fmt.Errorf("chainPath=%s: %w", chain.Path, err)
+	}
+
+	fmt.Printf("Fetched %d channels for connection %s on chain %s\n", len(channels.Channels), connectionID, chain.Path)
+
+	return &channels, nil
 }
@@ -0,0 +1,3 @@
+module github.com/gjermundgaraba/scripts
+
+go 1.23.3
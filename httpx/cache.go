@@ -0,0 +1,58 @@
+package httpx
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// GetJSONCached is like GetJSONLimit, but serves the response body from cacheFile while it is younger than ttl
+// Freshness is decided by the file's modification time, and every successful fetch rewrites the file.
+// A ttl of 0 or less always fetches, which is how callers force a refresh.
+func (c *Client) GetJSONCached(ctx context.Context, rawURL string, v interface{}, maxBytes int64, cacheFile string, ttl time.Duration) error {
+	if ttl > 0 {
+		if info, err := os.Stat(cacheFile); err == nil && time.Since(info.ModTime()) < ttl {
+			bodyBytes, err := os.ReadFile(cacheFile)
+			if err == nil && json.Unmarshal(bodyBytes, v) == nil {
+				return nil
+			}
+
+			// A broken cache file is not fatal, we just fetch again and overwrite it
+			log.Printf("Ignoring unreadable cache file %s", cacheFile)
+		}
+	}
+
+	bodyBytes, err := c.getBody(ctx, rawURL, maxBytes)
+	if err != nil {
+		return err
+	}
+
+	// Only cache bodies that decode, so a bad response isn't served again until the ttl runs out
+	if err := json.Unmarshal(bodyBytes, v); err != nil {
+		return fmt.Errorf("JSON unmarshal error: %w", err)
+	}
+
+	if err := writeCacheFile(cacheFile, bodyBytes); err != nil {
+		log.Printf("Failed to write cache file %s: %v", cacheFile, err)
+	}
+
+	return nil
+}
+
+// writeCacheFile writes the body to a temporary file first so an interrupted write never leaves a truncated cache
+func writeCacheFile(cacheFile string, bodyBytes []byte) error {
+	if err := os.MkdirAll(filepath.Dir(cacheFile), 0755); err != nil {
+		return err
+	}
+
+	tmpFile := cacheFile + ".tmp"
+	if err := os.WriteFile(tmpFile, bodyBytes, 0644); err != nil {
+		return err
+	}
+
+	return os.Rename(tmpFile, cacheFile)
+}
@@ -0,0 +1,322 @@
+// Package httpx provides a polite HTTP client shared by the fetch tools.
+// It spaces out requests to the same host, retries failed requests with backoff and gives up on hosts that keep failing.
+package httpx
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Client is an HTTP client that enforces a minimum interval between requests per host
+type Client struct {
+	httpClient    *http.Client
+	interval      time.Duration
+	hostIntervals map[string]time.Duration
+	retries       int
+
+	// KeyFunc decides which requests share a rate limit, defaults to the URL host
+	KeyFunc func(u *url.URL) string
+
+	// sharedHostInterval spaces out all requests to a host no matter their KeyFunc key, 0 disables it
+	sharedHostInterval time.Duration
+
+	// maxFailures is the number of consecutive failed attempts after which a host is skipped, 0 never skips
+	maxFailures int
+
+	// now and sleep are the clock used to space out requests, replaced in tests
+	now   func() time.Time
+	sleep func(ctx context.Context, d time.Duration) error
+
+	mu       sync.Mutex
+	next     map[string]time.Time
+	nextHost map[string]time.Time
+	failures map[string]int
+	open     map[string]bool
+}
+
+// ErrCircuitOpen is returned for requests to a host that was skipped for the rest of the run after failing too many times
+var ErrCircuitOpen = errors.New("host skipped after too many consecutive failures")
+
+// FirstPathSegmentKey is a KeyFunc rate limiting per host and first path segment rather than per host, for proxies
+// like rest.cosmos.directory that forward each chain (the first path segment) to that chain's own nodes
+func FirstPathSegmentKey(u *url.URL) string {
+	segments := strings.SplitN(strings.TrimPrefix(u.Path, "/"), "/", 2)
+	return u.Host + "/" + segments[0]
+}
+
+// NewClient creates a new client waiting at least interval between requests to the same host
+// and trying each request up to retries times. Requests are sent with httpClient, or
+// http.DefaultClient if nil, so proxies and TLS can be configured through its Transport.
+func NewClient(httpClient *http.Client, interval time.Duration, retries int) *Client {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+
+	return &Client{
+		httpClient:    httpClient,
+		interval:      interval,
+		hostIntervals: make(map[string]time.Duration),
+		retries:       retries,
+		next:          make(map[string]time.Time),
+		nextHost:      make(map[string]time.Time),
+		failures:      make(map[string]int),
+		open:          make(map[string]bool),
+		now:           time.Now,
+		sleep:         sleepCtx,
+	}
+}
+
+// SetMaxFailures skips all further requests to a host (or KeyFunc key) after maxFailures consecutive failed attempts,
+// so a dead endpoint fails fast instead of going through every retry for every request. 0 disables it.
+func (c *Client) SetMaxFailures(maxFailures int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.maxFailures = maxFailures
+}
+
+// SkippedHosts returns the hosts (or KeyFunc keys) that were skipped after too many consecutive failures
+func (c *Client) SkippedHosts() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	hosts := make([]string, 0, len(c.open))
+	for host := range c.open {
+		hosts = append(hosts, host)
+	}
+	sort.Strings(hosts)
+
+	return hosts
+}
+
+// SetHostInterval overrides the minimum interval between requests for a single host
+func (c *Client) SetHostInterval(host string, interval time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.hostIntervals[host] = interval
+}
+
+// SetSharedHostInterval spaces out all requests to the same host by at least interval, on top of the per-key interval
+// With a KeyFunc like FirstPathSegmentKey every key gets its own rate limit, so this keeps the host itself from being
+// flooded when many keys are requested at once. 0 disables it.
+func (c *Client) SetSharedHostInterval(interval time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.sharedHostInterval = interval
+}
+
+// GetJSON gets the url and unmarshals the JSON response body into v
+func (c *Client) GetJSON(ctx context.Context, rawURL string, v interface{}) error {
+	return c.GetJSONLimit(ctx, rawURL, v, 0)
+}
+
+// GetJSONLimit is like GetJSON, but fails if the response body is larger than maxBytes
+// A maxBytes of 0 or less means no limit
+func (c *Client) GetJSONLimit(ctx context.Context, rawURL string, v interface{}, maxBytes int64) error {
+	bodyBytes, err := c.getBody(ctx, rawURL, maxBytes)
+	if err != nil {
+		return err
+	}
+
+	if err := json.Unmarshal(bodyBytes, v); err != nil {
+		return fmt.Errorf("JSON unmarshal error: %w", err)
+	}
+
+	return nil
+}
+
+// getBody gets the url and returns the response body, failing if it is larger than maxBytes (0 or less means no limit)
+func (c *Client) getBody(ctx context.Context, rawURL string, maxBytes int64) ([]byte, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid url %s: %w", rawURL, err)
+	}
+
+	key := c.key(u)
+
+	var bodyBytes []byte
+	if err := retryWithBackoff(ctx, c.retries, func() error {
+		if c.isOpen(key) {
+			return fmt.Errorf("%w: %s", ErrCircuitOpen, key)
+		}
+
+		if err := c.wait(ctx, u); err != nil {
+			return err
+		}
+
+		var err error
+		if bodyBytes, err = c.get(ctx, rawURL, maxBytes); err != nil {
+			if ctx.Err() == nil && c.recordFailure(key) {
+				return fmt.Errorf("%w: %s: %w", ErrCircuitOpen, key, err)
+			}
+			return err
+		}
+
+		c.recordSuccess(key)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	return bodyBytes, nil
+}
+
+// get sends a single GET request and returns the response body
+func (c *Client) get(ctx context.Context, rawURL string, maxBytes int64) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("GET error: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status: %s with url=%s", resp.Status, rawURL)
+	}
+
+	var body io.Reader = resp.Body
+	if maxBytes > 0 {
+		// Read one byte more than allowed so an oversized body can be told apart from one exactly at the limit
+		body = io.LimitReader(resp.Body, maxBytes+1)
+	}
+
+	bodyBytes, err := io.ReadAll(body)
+	if err != nil {
+		return nil, fmt.Errorf("error reading response body: %w", err)
+	}
+
+	if maxBytes > 0 && int64(len(bodyBytes)) > maxBytes {
+		return nil, fmt.Errorf("response body from url=%s exceeds %d bytes", rawURL, maxBytes)
+	}
+
+	return bodyBytes, nil
+}
+
+// key returns the key of the url that rate limits and failures are tracked by
+func (c *Client) key(u *url.URL) string {
+	if c.KeyFunc != nil {
+		return c.KeyFunc(u)
+	}
+	return u.Host
+}
+
+// isOpen reports whether requests to the key are skipped
+func (c *Client) isOpen(key string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.open[key]
+}
+
+// recordFailure counts a failed attempt for the key and reports whether the key is now skipped
+func (c *Client) recordFailure(key string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.failures[key]++
+	if c.maxFailures > 0 && c.failures[key] >= c.maxFailures && !c.open[key] {
+		c.open[key] = true
+		log.Printf("Skipping %s for the rest of the run after %d consecutive failures", key, c.failures[key])
+	}
+
+	return c.open[key]
+}
+
+// recordSuccess resets the consecutive failures of the key
+func (c *Client) recordSuccess(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.failures, key)
+}
+
+// wait blocks until a request to the url is allowed
+func (c *Client) wait(ctx context.Context, u *url.URL) error {
+	key := c.key(u)
+
+	c.mu.Lock()
+	interval, ok := c.hostIntervals[u.Host]
+	if !ok {
+		interval = c.interval
+	}
+
+	now := c.now()
+	slot := c.next[key]
+	if slot.Before(now) {
+		slot = now
+	}
+	if c.sharedHostInterval > 0 {
+		if hostSlot := c.nextHost[u.Host]; hostSlot.After(slot) {
+			slot = hostSlot
+		}
+		c.nextHost[u.Host] = slot.Add(c.sharedHostInterval)
+	}
+	c.next[key] = slot.Add(interval)
+	c.mu.Unlock()
+
+	return c.sleep(ctx, slot.Sub(now))
+}
+
+// sleepCtx blocks for d or until ctx is done
+func sleepCtx(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// retryWithBackoff calls f up to retries times, waiting 5 seconds longer after each failure
+// It stops early when ctx is done or the host is skipped, and the returned error always wraps the last error from f
+// and says how many attempts were made
+func retryWithBackoff(ctx context.Context, retries int, f func() error) error {
+	var lastErr error
+	attempts := 0
+	for i := range retries {
+		attempts++
+		if lastErr = f(); lastErr == nil {
+			return nil
+		}
+		if errors.Is(lastErr, ErrCircuitOpen) {
+			return lastErr
+		}
+		if ctx.Err() != nil || i == retries-1 {
+			break
+		}
+
+		wait := time.Duration(i*5) * time.Second
+		log.Printf("Error: %v. Retrying in %s...", lastErr, wait)
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return fmt.Errorf("retry cancelled after %d attempts: %w", attempts, lastErr)
+		case <-timer.C:
+		}
+	}
+	if ctx.Err() != nil {
+		return fmt.Errorf("retry cancelled after %d attempts: %w", attempts, lastErr)
+	}
+	return fmt.Errorf("retries exhausted after %d attempts: %w", attempts, lastErr)
+}
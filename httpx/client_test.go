@@ -0,0 +1,384 @@
+package httpx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeClock is a clock that only moves when something sleeps
+type fakeClock struct {
+	now time.Time
+}
+
+func (f *fakeClock) Now() time.Time {
+	return f.now
+}
+
+func (f *fakeClock) Sleep(ctx context.Context, d time.Duration) error {
+	f.now = f.now.Add(d)
+	return ctx.Err()
+}
+
+// newFakeClockClient creates a client on a fake clock and returns the clock and its start time
+func newFakeClockClient(interval time.Duration) (*Client, *fakeClock, time.Time) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := &fakeClock{now: start}
+
+	c := NewClient(nil, interval, 1)
+	c.now = clock.Now
+	c.sleep = clock.Sleep
+
+	return c, clock, start
+}
+
+func TestWaitSpacesRequestsPerKey(t *testing.T) {
+	tests := []struct {
+		name    string
+		keyFunc func(u *url.URL) string
+		urls    []string
+		// want is when each request is sent, as an offset from the start
+		want []time.Duration
+	}{
+		{
+			name: "same host is spaced by the interval",
+			urls: []string{"https://a.example/1", "https://a.example/2", "https://a.example/3"},
+			want: []time.Duration{0, time.Second, 2 * time.Second},
+		},
+		{
+			name: "other hosts don't wait for each other",
+			urls: []string{"https://a.example/1", "https://b.example/1", "https://a.example/2", "https://b.example/2"},
+			want: []time.Duration{0, 0, time.Second, time.Second},
+		},
+		{
+			name:    "first path segments are separate keys",
+			keyFunc: FirstPathSegmentKey,
+			urls:    []string{"https://proxy.example/osmosis/a", "https://proxy.example/cosmoshub/a", "https://proxy.example/osmosis/b"},
+			want:    []time.Duration{0, 0, time.Second},
+		},
+		{
+			name: "same first path segment shares the host key by default",
+			urls: []string{"https://proxy.example/osmosis/a", "https://proxy.example/cosmoshub/a"},
+			want: []time.Duration{0, time.Second},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, clock, start := newFakeClockClient(time.Second)
+			c.KeyFunc = tt.keyFunc
+
+			for i, rawURL := range tt.urls {
+				u, err := url.Parse(rawURL)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if err := c.wait(context.Background(), u); err != nil {
+					t.Fatalf("wait(%s) error = %v", rawURL, err)
+				}
+				if got := clock.now.Sub(start); got != tt.want[i] {
+					t.Errorf("request %d to %s sent at %s, want %s", i, rawURL, got, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestWaitHostInterval(t *testing.T) {
+	c, clock, start := newFakeClockClient(time.Second)
+	c.SetHostInterval("slow.example", 5*time.Second)
+
+	u, _ := url.Parse("https://slow.example/x")
+	for i, want := range []time.Duration{0, 5 * time.Second, 10 * time.Second} {
+		if err := c.wait(context.Background(), u); err != nil {
+			t.Fatal(err)
+		}
+		if got := clock.now.Sub(start); got != want {
+			t.Errorf("request %d sent at %s, want %s", i, got, want)
+		}
+	}
+}
+
+func TestWaitSharedHostInterval(t *testing.T) {
+	c, clock, start := newFakeClockClient(time.Second)
+	c.KeyFunc = FirstPathSegmentKey
+	c.SetSharedHostInterval(100 * time.Millisecond)
+
+	// Other chains only wait for the shared host interval, the same chain still waits for the full interval
+	urls := []string{"https://proxy.example/osmosis/a", "https://proxy.example/cosmoshub/a", "https://proxy.example/juno/a", "https://proxy.example/osmosis/b", "https://other.example/osmosis/a"}
+	want := []time.Duration{0, 100 * time.Millisecond, 200 * time.Millisecond, time.Second, time.Second}
+	for i, rawURL := range urls {
+		u, _ := url.Parse(rawURL)
+		if err := c.wait(context.Background(), u); err != nil {
+			t.Fatal(err)
+		}
+		if got := clock.now.Sub(start); got != want[i] {
+			t.Errorf("request %d to %s sent at %s, want %s", i, rawURL, got, want[i])
+		}
+	}
+}
+
+func TestWaitIdleKeyDoesNotBankSlots(t *testing.T) {
+	c, clock, start := newFakeClockClient(time.Second)
+	u, _ := url.Parse("https://a.example/x")
+
+	if err := c.wait(context.Background(), u); err != nil {
+		t.Fatal(err)
+	}
+
+	// After being idle for longer than the interval, the next request goes out right away, but only one
+	clock.now = clock.now.Add(10 * time.Second)
+	for i, want := range []time.Duration{10 * time.Second, 11 * time.Second} {
+		if err := c.wait(context.Background(), u); err != nil {
+			t.Fatal(err)
+		}
+		if got := clock.now.Sub(start); got != want {
+			t.Errorf("request %d sent at %s, want %s", i, got, want)
+		}
+	}
+}
+
+func TestRetryWithBackoff(t *testing.T) {
+	errUnavailable := errors.New("unexpected status: 503 Service Unavailable")
+
+	tests := []struct {
+		name string
+		// fail returns the error of the attempt, cancel is called with it so attempts can cancel the context
+		fail         func(attempt int, cancel context.CancelFunc) error
+		retries      int
+		wantErr      error
+		wantAttempts int
+		wantMessage  string
+	}{
+		{
+			name: "succeeds after a failure",
+			fail: func(attempt int, _ context.CancelFunc) error {
+				if attempt == 1 {
+					return errUnavailable
+				}
+				return nil
+			},
+			retries:      2,
+			wantAttempts: 2,
+		},
+		{
+			name: "exhausted wraps the last error",
+			fail: func(attempt int, _ context.CancelFunc) error {
+				if attempt == 1 {
+					return errors.New("connection reset")
+				}
+				return errUnavailable
+			},
+			retries:      2,
+			wantErr:      errUnavailable,
+			wantAttempts: 2,
+			wantMessage:  "retries exhausted after 2 attempts: unexpected status: 503 Service Unavailable",
+		},
+		{
+			name: "cancelled stops retrying",
+			fail: func(attempt int, cancel context.CancelFunc) error {
+				if attempt == 2 {
+					cancel()
+				}
+				return errUnavailable
+			},
+			retries:      5,
+			wantErr:      errUnavailable,
+			wantAttempts: 2,
+			wantMessage:  "retry cancelled after 2 attempts: unexpected status: 503 Service Unavailable",
+		},
+		{
+			name:         "open circuit is not retried",
+			fail:         func(int, context.CancelFunc) error { return ErrCircuitOpen },
+			retries:      5,
+			wantErr:      ErrCircuitOpen,
+			wantAttempts: 1,
+			wantMessage:  ErrCircuitOpen.Error(),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			attempts := 0
+			err := retryWithBackoff(ctx, tt.retries, func() error {
+				attempts++
+				return tt.fail(attempts, cancel)
+			})
+
+			if attempts != tt.wantAttempts {
+				t.Errorf("f called %d times, want %d", attempts, tt.wantAttempts)
+			}
+			if tt.wantErr == nil {
+				if err != nil {
+					t.Errorf("retryWithBackoff() error = %v, want nil", err)
+				}
+				return
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("retryWithBackoff() error = %v, want it to wrap %v", err, tt.wantErr)
+			}
+			if err.Error() != tt.wantMessage {
+				t.Errorf("retryWithBackoff() error = %q, want %q", err.Error(), tt.wantMessage)
+			}
+		})
+	}
+}
+
+// newStatusServer serves status to every request and counts the requests
+func newStatusServer(t *testing.T, status int) (*httptest.Server, *atomic.Int64) {
+	t.Helper()
+
+	var requests atomic.Int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.WriteHeader(status)
+		fmt.Fprint(w, `{}`)
+	}))
+	t.Cleanup(server.Close)
+
+	return server, &requests
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	dead, deadRequests := newStatusServer(t, http.StatusServiceUnavailable)
+	alive, aliveRequests := newStatusServer(t, http.StatusOK)
+
+	// A single attempt per request keeps the backoff out of the test
+	c := NewClient(nil, 0, 1)
+	c.SetMaxFailures(3)
+
+	var v struct{}
+	for i := 0; i < 3; i++ {
+		err := c.GetJSON(context.Background(), dead.URL+"/x", &v)
+		if err == nil {
+			t.Fatalf("request %d to the dead host succeeded", i)
+		}
+		if opened := errors.Is(err, ErrCircuitOpen); opened != (i == 2) {
+			t.Errorf("request %d: errors.Is(err, ErrCircuitOpen) = %v, error = %v", i, opened, err)
+		}
+	}
+
+	// The dead host is skipped without sending anything
+	if err := c.GetJSON(context.Background(), dead.URL+"/y", &v); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("request after the circuit opened: error = %v, want ErrCircuitOpen", err)
+	}
+	if got := deadRequests.Load(); got != 3 {
+		t.Errorf("dead host got %d requests, want 3", got)
+	}
+
+	// Other hosts are unaffected
+	if err := c.GetJSON(context.Background(), alive.URL+"/x", &v); err != nil {
+		t.Errorf("request to the other host: error = %v", err)
+	}
+	if got := aliveRequests.Load(); got != 1 {
+		t.Errorf("other host got %d requests, want 1", got)
+	}
+
+	deadURL, _ := url.Parse(dead.URL)
+	if got := c.SkippedHosts(); len(got) != 1 || got[0] != deadURL.Host {
+		t.Errorf("SkippedHosts() = %v, want [%s]", got, deadURL.Host)
+	}
+}
+
+func TestCircuitBreakerNeedsConsecutiveFailures(t *testing.T) {
+	var requests atomic.Int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Every third request succeeds, so there are never 3 failures in a row
+		if requests.Add(1)%3 == 0 {
+			fmt.Fprint(w, `{}`)
+			return
+		}
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	t.Cleanup(server.Close)
+
+	c := NewClient(nil, 0, 1)
+	c.SetMaxFailures(3)
+
+	var v struct{}
+	for i := 0; i < 9; i++ {
+		if err := c.GetJSON(context.Background(), server.URL, &v); errors.Is(err, ErrCircuitOpen) {
+			t.Fatalf("request %d opened the circuit: %v", i, err)
+		}
+	}
+	if got := c.SkippedHosts(); len(got) != 0 {
+		t.Errorf("SkippedHosts() = %v, want none", got)
+	}
+}
+
+func TestCircuitBreakerPerKey(t *testing.T) {
+	var requests atomic.Int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		if strings.HasPrefix(r.URL.Path, "/dead/") {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprint(w, `{}`)
+	}))
+	t.Cleanup(server.Close)
+
+	c := NewClient(nil, 0, 1)
+	c.KeyFunc = FirstPathSegmentKey
+	c.SetMaxFailures(2)
+
+	var v struct{}
+	for i := 0; i < 3; i++ {
+		_ = c.GetJSON(context.Background(), server.URL+"/dead/x", &v)
+	}
+	if err := c.GetJSON(context.Background(), server.URL+"/alive/x", &v); err != nil {
+		t.Errorf("request to another path segment: error = %v", err)
+	}
+	if got := requests.Load(); got != 3 {
+		t.Errorf("server got %d requests, want 2 to the dead segment and 1 to the alive one", got)
+	}
+
+	serverURL, _ := url.Parse(server.URL)
+	if got := c.SkippedHosts(); len(got) != 1 || got[0] != serverURL.Host+"/dead" {
+		t.Errorf("SkippedHosts() = %v, want [%s/dead]", got, serverURL.Host)
+	}
+}
+
+func TestCircuitBreakerDisabled(t *testing.T) {
+	dead, deadRequests := newStatusServer(t, http.StatusServiceUnavailable)
+
+	c := NewClient(nil, 0, 1)
+
+	var v struct{}
+	for i := 0; i < 10; i++ {
+		if err := c.GetJSON(context.Background(), dead.URL, &v); errors.Is(err, ErrCircuitOpen) {
+			t.Fatalf("request %d opened the circuit without SetMaxFailures: %v", i, err)
+		}
+	}
+	if got := deadRequests.Load(); got != 10 {
+		t.Errorf("dead host got %d requests, want 10", got)
+	}
+}
+
+func TestCircuitBreakerIgnoresCancellation(t *testing.T) {
+	alive, _ := newStatusServer(t, http.StatusOK)
+
+	c := NewClient(nil, 0, 1)
+	c.SetMaxFailures(1)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	var v struct{}
+	if err := c.GetJSON(ctx, alive.URL, &v); !errors.Is(err, context.Canceled) || errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("cancelled request: error = %v, want context.Canceled", err)
+	}
+	if got := c.SkippedHosts(); len(got) != 0 {
+		t.Errorf("SkippedHosts() = %v, want none after a cancelled request", got)
+	}
+}
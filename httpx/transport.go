@@ -0,0 +1,100 @@
+package httpx
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"time"
+)
+
+// TransportConfig configures how requests are dialed, for nodes that are only reachable over IPv6,
+// only resolvable through an internal DNS server or served with a self-signed certificate
+type TransportConfig struct {
+	// Network is "tcp" to use IPv4 or IPv6 (the default), "tcp4" to force IPv4 or "tcp6" to force IPv6
+	Network string
+	// DNSServer is the host:port of a DNS server used instead of the system resolver, port 53 if left out
+	DNSServer string
+	// CACertFile is a PEM file with certificates trusted in addition to the system roots, e.g. an internal testnet CA
+	CACertFile string
+	// Insecure skips TLS certificate verification entirely, only meant for local nodes with self-signed certificates
+	Insecure bool
+}
+
+// NewHTTPClient creates an http.Client dialing according to the config, an empty config behaves like http.DefaultClient
+func NewHTTPClient(config TransportConfig) (*http.Client, error) {
+	network := config.Network
+	switch network {
+	case "":
+		network = "tcp"
+	case "tcp", "tcp4", "tcp6":
+	default:
+		return nil, fmt.Errorf("invalid network %q, must be tcp, tcp4 or tcp6", network)
+	}
+
+	dialer := &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+	}
+
+	if config.DNSServer != "" {
+		dnsServer := config.DNSServer
+		if _, _, err := net.SplitHostPort(dnsServer); err != nil {
+			dnsServer = net.JoinHostPort(dnsServer, "53")
+		}
+
+		dialer.Resolver = &net.Resolver{
+			// The Go resolver is needed for Dial to be used, the cgo resolver always asks the system
+			PreferGo: true,
+			Dial: func(ctx context.Context, dnsNetwork, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, dnsNetwork, dnsServer)
+			},
+		}
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = func(ctx context.Context, _, addr string) (net.Conn, error) {
+		return dialer.DialContext(ctx, network, addr)
+	}
+
+	if config.CACertFile != "" || config.Insecure {
+		tlsConfig, err := newTLSConfig(config.CACertFile, config.Insecure)
+		if err != nil {
+			return nil, err
+		}
+		transport.TLSClientConfig = tlsConfig
+	}
+
+	return &http.Client{Transport: transport}, nil
+}
+
+// newTLSConfig creates a TLS config trusting the certificates in caCertFile on top of the system roots,
+// or not verifying certificates at all if insecure is set
+func newTLSConfig(caCertFile string, insecure bool) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: insecure,
+	}
+
+	if caCertFile != "" {
+		pem, err := os.ReadFile(caCertFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA certificate: %w", err)
+		}
+
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no PEM certificates found in %s", caCertFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	return tlsConfig, nil
+}